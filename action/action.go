@@ -4,8 +4,11 @@ import "reflect"
 
 type Fn[T any] func(T)
 
+// ActionSet applies its actions in the order they were first
+// added, so that the same updates always give the same result.
 type ActionSet[T any] struct {
 	actions map[uintptr]Fn[T]
+	order   []uintptr
 	removed []uintptr
 	clear   bool
 }
@@ -19,22 +22,71 @@ func NewSet[T any]() *ActionSet[T] {
 
 func (actionSet *ActionSet[T]) Add(fn Fn[T]) Fn[T] {
 	ptr := reflect.ValueOf(fn).Pointer()
+	if !actionSet.known(ptr) {
+		actionSet.order = append(actionSet.order, ptr)
+	}
 	actionSet.actions[ptr] = fn
 	return fn
 }
 
+// known returns true if an action with ptr was ever added,
+// keeping its place in the order even after it's removed.
+func (actionSet *ActionSet[T]) known(ptr uintptr) bool {
+	for _, p := range actionSet.order {
+		if p == ptr {
+			return true
+		}
+	}
+	return false
+}
+
 func (actionSet *ActionSet[T]) Remove(fn Fn[T]) {
 	ptr := reflect.ValueOf(fn).Pointer()
 	actionSet.removed = append(actionSet.removed, ptr)
 }
 
+// Has returns true if fn is in the set and is not going
+// to be removed on the next apply.
+func (actionSet *ActionSet[T]) Has(fn Fn[T]) bool {
+	ptr := reflect.ValueOf(fn).Pointer()
+	if _, ok := actionSet.actions[ptr]; !ok || actionSet.clear {
+		return false
+	}
+	for _, removed := range actionSet.removed {
+		if removed == ptr {
+			return false
+		}
+	}
+	return true
+}
+
+// Set adds fn to the set if on is true, cancelling a pending
+// remove, otherwise it removes fn.
+func (actionSet *ActionSet[T]) Set(fn Fn[T], on bool) {
+	if !on {
+		actionSet.Remove(fn)
+		return
+	}
+	ptr := reflect.ValueOf(fn).Pointer()
+	removed := actionSet.removed[:0]
+	for _, p := range actionSet.removed {
+		if p != ptr {
+			removed = append(removed, p)
+		}
+	}
+	actionSet.removed = removed
+	actionSet.Add(fn)
+}
+
 func (actionSet *ActionSet[T]) ClearNextApply() {
 	actionSet.clear = true
 }
 
 func (actionSet *ActionSet[T]) Apply(x T) {
-	for _, fn := range actionSet.actions {
-		fn(x)
+	for _, ptr := range actionSet.order {
+		if fn, ok := actionSet.actions[ptr]; ok {
+			fn(x)
+		}
 	}
 	if actionSet.clear {
 		for ptr := range actionSet.actions {
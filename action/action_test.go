@@ -0,0 +1,32 @@
+package action
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyOrder(t *testing.T) {
+	var calls []string
+	a := func(s string) { calls = append(calls, "a"+s) }
+	b := func(s string) { calls = append(calls, "b"+s) }
+	c := func(s string) { calls = append(calls, "c"+s) }
+
+	set := NewSet[string]()
+	set.Add(a)
+	set.Add(b)
+	set.Add(c)
+	set.Remove(a)
+	set.Apply("1")
+	set.Apply("2")
+
+	// Added back, a keeps its place before b.
+	set.Add(a)
+	set.Remove(c)
+	set.Apply("3")
+	set.Apply("4")
+
+	expected := []string{"a1", "b1", "c1", "b2", "c2", "a3", "b3", "c3", "a4", "b4"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected %v, got %v", expected, calls)
+	}
+}
@@ -5,8 +5,15 @@ import "embed"
 //go:embed dinosprites-vita.png
 var DinoSpriteData []byte
 
+//go:embed tuning.txt
+var TuningData []byte
+
+//go:embed bindings.txt
+var BindingsData []byte
+
 //go:embed dinosprites-doux.png
 //go:embed dinosprites-vita.png
 //go:embed "Cielo pixelado.png"
 //go:embed lemcraft-tiles.png
+//go:embed levels
 var FS embed.FS
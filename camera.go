@@ -7,9 +7,16 @@ import (
 	"github.com/nvlled/dinojump/vector"
 )
 
+// maxCameraLag is how far the camera can move in an update and still
+// be interpolated, so that it jumps to a new level or a restored game.
+const maxCameraLag = 100
+
 type Camera struct {
 	Rect      rect.T
 	InnerRect rect.T
+
+	// PrevRect is where the camera was before the last Follow.
+	PrevRect rect.T
 }
 
 func NewCamera(centerX, centerY, width, height, innerSize float64) *Camera {
@@ -30,6 +37,7 @@ func (camera *Camera) CenterAt(pos *vector.T) {
 }
 
 func (camera *Camera) Follow(pos *vector.T) {
+	camera.PrevRect = camera.Rect
 	r := &camera.InnerRect
 	if pos.X < r.Left() {
 		r.SetLeft(pos.X)
@@ -45,8 +53,20 @@ func (camera *Camera) Follow(pos *vector.T) {
 	camera.Rect.SetMidXY(camera.InnerRect.MidXY())
 }
 
-func (camera *Camera) Render(world *ebiten.Image) *ebiten.Image {
-	x, y := camera.Rect.XY()
-	w, h := camera.Rect.Dimension()
+// View returns the rect of the camera, lag of the way back to
+// where it was before the last Follow.
+func (camera *Camera) View(lag float64) rect.T {
+	r := camera.Rect
+	if d := vector.Sub(&camera.PrevRect.Min, &r.Min); d.Length() < maxCameraLag {
+		d.Scale(lag)
+		r.Min.Add(&d)
+		r.Max.Add(&d)
+	}
+	return r
+}
+
+func (camera *Camera) Render(world *ebiten.Image, view *rect.T) *ebiten.Image {
+	x, y := view.XY()
+	w, h := view.Dimension()
 	return world.SubImage(common.ImageRect(x, y, w, h)).(*ebiten.Image)
 }
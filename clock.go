@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// timeScales are the speeds that F12 cycles through, starting from 1x.
+var timeScales = []float64{1, 2, 0.25, 0.5}
+
+// TickRate is how many times per second the game updates,
+// regardless of how often ebiten updates and draws.
+const TickRate = 60
+
+// maxFrameTime is the longest time between ebiten updates that is
+// caught up with. Longer ones, like when the game was in the pause
+// menu or the window was dragged, only advance a single tick.
+const maxFrameTime = time.Second / 10
+
+// Clock decides how many fixed game ticks run on each ebiten update,
+// from the time that passed since the last one. The game can also be
+// paused, stepped, slowed down or sped up.
+type Clock struct {
+	Paused bool
+
+	scale int
+	step  bool
+	last  time.Time
+
+	// acc is the fraction of a tick that hasn't run yet.
+	acc float64
+}
+
+func (clock *Clock) Scale() float64 {
+	return timeScales[clock.scale]
+}
+
+func (clock *Clock) NextScale() {
+	clock.scale = (clock.scale + 1) % len(timeScales)
+	clock.acc = 0
+}
+
+// Step makes the next Advance run a single tick while paused.
+func (clock *Clock) Step() {
+	clock.step = true
+}
+
+// Advance returns how many game ticks to run on this ebiten update.
+func (clock *Clock) Advance(now time.Time) int {
+	dt := now.Sub(clock.last)
+	if clock.last.IsZero() || dt > maxFrameTime {
+		dt = time.Second / TickRate
+	}
+	clock.last = now
+
+	if clock.Paused {
+		n := 0
+		if clock.step {
+			n = 1
+		}
+		clock.step = false
+		return n
+	}
+	clock.acc += dt.Seconds() * TickRate * clock.Scale()
+	n := int(clock.acc)
+	clock.acc -= float64(n)
+	return n
+}
+
+// Lag is how far back between the last two ticks the game is drawn.
+func (clock *Clock) Lag() float64 {
+	if clock.Paused {
+		return 0
+	}
+	return 1 - clock.acc
+}
+
+func (clock Clock) String() string {
+	if clock.Paused {
+		return "paused (F10 to resume, F11 to step)"
+	}
+	return fmt.Sprintf("%vx (F10 to pause, F12 to change)", clock.Scale())
+}
+
+func (g *Game) updateClock() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyF10) {
+		g.clock.Paused = !g.clock.Paused
+		println("paused:", g.clock.Paused)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyF11) {
+		g.clock.Paused = true
+		g.clock.Step()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyF12) {
+		g.clock.NextScale()
+		println("time scale:", g.clock.Scale())
+	}
+}
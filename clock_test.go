@@ -0,0 +1,120 @@
+//go:build !nodisplay
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+const tick = time.Second / TickRate
+
+func TestClockPauseAndStep(t *testing.T) {
+	var clock Clock
+	now := time.Now()
+	clock.Advance(now)
+
+	clock.Paused = true
+	for i := 0; i < 5; i++ {
+		now = now.Add(tick)
+		if n := clock.Advance(now); n != 0 {
+			t.Fatalf("expected no ticks while paused, got %v", n)
+		}
+	}
+	if clock.Lag() != 0 {
+		t.Errorf("expected no lag while paused, got %v", clock.Lag())
+	}
+
+	clock.Step()
+	now = now.Add(time.Second)
+	if n := clock.Advance(now); n != 1 {
+		t.Errorf("expected a single tick after stepping, got %v", n)
+	}
+	now = now.Add(tick)
+	if n := clock.Advance(now); n != 0 {
+		t.Errorf("expected a step to run only once, got %v", n)
+	}
+
+	// Time that passed while paused isn't caught up with.
+	clock.Paused = false
+	now = now.Add(tick)
+	if n := clock.Advance(now); n != 1 {
+		t.Errorf("expected a tick after resuming, got %v", n)
+	}
+}
+
+func TestClockScale(t *testing.T) {
+	var clock Clock
+	now := time.Now()
+	clock.Advance(now)
+
+	for _, scale := range timeScales[1:] {
+		clock.NextScale()
+		if clock.Scale() != scale {
+			t.Fatalf("expected the time scale %v, got %v", scale, clock.Scale())
+		}
+		total := 0
+		for i := 0; i < 2*TickRate; i++ {
+			now = now.Add(tick)
+			total += clock.Advance(now)
+		}
+		if expected := int(2 * TickRate * scale); total < expected-1 || total > expected {
+			t.Errorf("%vx: expected %v ticks in 2 seconds, got %v", scale, expected, total)
+		}
+	}
+
+	clock.NextScale()
+	if clock.Scale() != 1 {
+		t.Errorf("expected to cycle back to 1x, got %v", clock.Scale())
+	}
+}
+
+func TestClockFixedTimestep(t *testing.T) {
+	cases := []struct {
+		name   string
+		frames []time.Duration
+	}{
+		{"60 Hz", []time.Duration{tick}},
+		{"144 Hz", []time.Duration{time.Second / 144}},
+		{"30 Hz", []time.Duration{time.Second / 30}},
+		{"uneven", []time.Duration{5 * time.Millisecond, 30 * time.Millisecond, 12 * time.Millisecond, 21 * time.Millisecond}},
+	}
+	for _, c := range cases {
+		// The first update runs the time of a tick.
+		var clock Clock
+		now := time.Now()
+		total := clock.Advance(now)
+		elapsed := tick
+		for i := 0; i < 200; i++ {
+			frame := c.frames[i%len(c.frames)]
+			elapsed += frame
+			now = now.Add(frame)
+			total += clock.Advance(now)
+
+			if lag := clock.Lag(); lag <= 0 || lag > 1 {
+				t.Fatalf("%v: expected the lag in (0, 1], got %v", c.name, lag)
+			}
+		}
+		// Less than a tick can still be in the accumulator.
+		if expected := int(elapsed.Seconds() * TickRate); total < expected-1 || total > expected {
+			t.Errorf("%v: expected %v ticks in %v, got %v", c.name, expected, elapsed, total)
+		}
+	}
+}
+
+func TestClockLongFrame(t *testing.T) {
+	var clock Clock
+	now := time.Now()
+	clock.Advance(now)
+
+	// Frames longer than maxFrameTime aren't caught up with.
+	now = now.Add(5 * time.Second)
+	if n := clock.Advance(now); n != 1 {
+		t.Errorf("expected a single tick after a long frame, got %v", n)
+	}
+
+	now = now.Add(maxFrameTime)
+	if n, expected := clock.Advance(now), int(maxFrameTime/tick); n < expected-1 || n > expected {
+		t.Errorf("expected %v ticks for a frame of %v, got %v", expected, maxFrameTime, n)
+	}
+}
@@ -0,0 +1,133 @@
+// Command parity feeds the same input scripts to all the dino
+// implementations and reports where their states first diverge.
+//
+//	go run ./cmd/parity
+//	go run ./cmd/parity -script jump -v
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/nvlled/dinojump/dinos"
+	"github.com/nvlled/dinojump/input"
+	"github.com/nvlled/dinojump/sim"
+)
+
+var (
+	left  = input.Left
+	right = input.Right
+	up    = input.Up
+	down  = input.Down
+	space = input.Jump
+)
+
+var scripts = map[string]func() *input.Script{
+	"idle": func() *input.Script {
+		return input.NewScript().Wait(120)
+	},
+	"walk": func() *input.Script {
+		return input.NewScript().Wait(30).Hold(40, right).Wait(60)
+	},
+	"brake": func() *input.Script {
+		return input.NewScript().Wait(30).Hold(100, right).Hold(30, left).Wait(60)
+	},
+	"jump": func() *input.Script {
+		return input.NewScript().Wait(30).Hold(20, space).Wait(90)
+	},
+	"double-jump": func() *input.Script {
+		return input.NewScript().
+			Wait(30).Hold(15, space).Wait(10).
+			Hold(15, space, right).Hold(60, right).Wait(60)
+	},
+	"run-jump": func() *input.Script {
+		return input.NewScript().
+			Wait(30).Hold(100, right).Hold(20, right, space).Hold(60, right).Wait(60)
+	},
+	"fly": func() *input.Script {
+		return input.NewScript().
+			Wait(30).Hold(150, right).
+			Hold(10, right, space).Hold(5, right).
+			Hold(10, right, space).Hold(5, right).
+			Hold(10, right, space).Hold(30, right).
+			Hold(30, up).Hold(10, down, space).Wait(90)
+	},
+}
+
+func main() {
+	scriptName := flag.String("script", "", "name of the script to run, runs all scripts if empty")
+	eps := flag.Float64("eps", 1e-9, "tolerance when comparing float values")
+	verbose := flag.Bool("v", false, "print the samples of every tick")
+	flag.Parse()
+
+	var names []string
+	if *scriptName != "" {
+		if _, ok := scripts[*scriptName]; !ok {
+			fmt.Fprintf(os.Stderr, "unknown script %q\n", *scriptName)
+			os.Exit(2)
+		}
+		names = append(names, *scriptName)
+	} else {
+		for name := range scripts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	level, err := sim.NewArena()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	spawn, _ := level.SpawnPoint()
+
+	diverged := false
+	for _, name := range names {
+		script := scripts[name]()
+		trace := sim.Trace(level, spawn, script.States, dinos.All)
+
+		fmt.Printf("== %v (%v ticks)\n", name, script.Len())
+		if *verbose {
+			printTrace(trace)
+		}
+
+		divs := sim.FindDivergences(trace, *eps)
+		if len(divs) == 0 {
+			fmt.Println("no divergence")
+		}
+		for _, div := range divs {
+			diverged = true
+			fmt.Printf("%-5v first diverges at tick %v\n", div.Field, div.Tick)
+			for i, sample := range div.Samples {
+				fmt.Printf("\t%-10v %v\n", dinos.All[i].Name, formatSample(sample))
+			}
+		}
+		fmt.Println()
+	}
+
+	if diverged {
+		os.Exit(1)
+	}
+}
+
+func printTrace(trace [][]sim.Sample) {
+	for tick := range trace[0] {
+		for i, samples := range trace {
+			if tick < len(samples) {
+				fmt.Printf("%5v %-10v %v\n", samples[tick].Tick, dinos.All[i].Name, formatSample(samples[tick]))
+			}
+		}
+	}
+}
+
+func formatSample(sample sim.Sample) string {
+	return fmt.Sprintf(
+		"state=%-12v pos=(%.2f, %.2f) vel=(%.2f, %.2f) tile=%v",
+		sample.State,
+		sample.Pos.X, sample.Pos.Y,
+		sample.Vel.X, sample.Vel.Y,
+		sample.TileID,
+	)
+}
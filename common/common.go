@@ -4,9 +4,8 @@ import (
 	"image"
 	"image/color"
 
-	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/nvlled/carrot"
+	"github.com/nvlled/dinojump/input"
 )
 
 type Void struct{}
@@ -37,14 +36,16 @@ func RangeSlice(start, end int) []int {
 	return nums
 }
 
-func AwaitKey(ctrl carrot.Control, key ebiten.Key, abortOpt ...*bool) {
+// AwaitKey yields until the action is just pressed in the source,
+// or until abort is set to true.
+func AwaitKey(ctrl *carrot.Control, source input.Source, action input.Action, abortOpt ...*bool) {
 	var abort *bool
 	if len(abortOpt) > 0 {
 		abort = abortOpt[0]
 	}
 
 	for abort == nil || !*abort {
-		if inpututil.IsKeyJustPressed(key) {
+		if source.IsJustPressed(action) {
 			break
 		}
 		ctrl.Yield()
@@ -12,20 +12,58 @@ import (
 	"github.com/nvlled/dinojump/assets"
 	"github.com/nvlled/dinojump/bitf"
 	"github.com/nvlled/dinojump/common"
+	"github.com/nvlled/dinojump/input"
 	"github.com/nvlled/dinojump/level"
+	"github.com/nvlled/dinojump/life"
 	"github.com/nvlled/dinojump/numsign"
+	"github.com/nvlled/dinojump/rect"
 	"github.com/nvlled/dinojump/seqiter"
 	"github.com/nvlled/dinojump/sprite"
+	"github.com/nvlled/dinojump/tuning"
+	"github.com/nvlled/dinojump/vector"
 )
 
-var dinoMaxSpeed = 20
-
 type Sprite struct {
 	sprite.T
 
 	Hit bitf.T
 
+	// Ground are the flags of the tile the dino stands on,
+	// Touch are the flags of all the tiles it touches.
+	Ground uint16
+	Touch  uint16
+
+	dropThrough bool
+
+	// platform is the moving platform the dino stands on.
+	platform *level.Platform
+
+	// lastRect is where the collision rect was resolved in the previous
+	// tick, and endPos is where the dino was at the end of the previous tick.
+	// The dino is swept from lastRect unless it was moved from endPos
+	// outside of Update.
+	lastRect   rect.T
+	endPos     vector.T
+	teleported bool
+
 	Level *level.T
+	Input input.Source
+	Life  life.T
+
+	state string
+
+	// resume is the state that the controller starts
+	// from instead of idle, for restoring a saved state.
+	resume string
+
+	turns int
+	jumps int
+
+	// chargeSize is the draw size from before the jump charge,
+	// or zero when the dino isn't charging.
+	chargeSize vector.T
+
+	rng *rand.Rand
 
 	animationScript  *carrot.Script
 	controllerScript *carrot.Script
@@ -41,6 +79,8 @@ func New(level *level.T) *Sprite {
 	dino := &Sprite{
 		T:                *sprite.New(img, 24, 1),
 		Level:            level,
+		Input:            input.Ebiten,
+		rng:              rand.New(rand.NewSource(1)),
 		animationScript:  carrot.Create(),
 		controllerScript: carrot.Create(),
 	}
@@ -60,7 +100,15 @@ func New(level *level.T) *Sprite {
 }
 
 func (dino *Sprite) Update() {
+	dino.Touch = 0
+	dino.teleported = dino.Pos != dino.endPos
 	dino.T.Update()
+	if dino.state != "dead" {
+		dino.Life.Update(dino.Level, dino.Pos, &dino.Rect)
+		if dino.Touch&level.FlagHazard != 0 || life.OutOfWorld(dino.Level, &dino.Rect) {
+			dino.controllerScript.Transition(dino.ControlDead)
+		}
+	}
 	dino.controllerScript.Update()
 	dino.animationScript.Update()
 
@@ -73,6 +121,29 @@ func (dino *Sprite) Update() {
 		dino.animationScript.Transition(dino.AnimateIdle)
 		dino.controllerScript.Transition(dino.ControllerCoroutine)
 	}
+
+	dino.endPos = dino.Pos
+}
+
+func (dino *Sprite) SetLevel(level *level.T) {
+	dino.Level = level
+}
+
+func (dino *Sprite) SetInput(source input.Source) {
+	dino.Input = source
+}
+
+func (dino *Sprite) GetLife() *life.T {
+	return &dino.Life
+}
+
+func (dino *Sprite) StateName() string {
+	return dino.state
+}
+
+func (dino *Sprite) setState(name string) {
+	println(name)
+	dino.state = name
 }
 
 func (dino *Sprite) SetAnimation(coroutine carrot.Coroutine) {
@@ -111,7 +182,7 @@ func (dino *Sprite) AnimateRun(ctrl *carrot.Control) {
 	frames := seqiter.CreateSeqIterator(common.RangeSlice(18, 23)...)
 	for {
 		dino.CurrentTileID = frames.Next()
-		if dino.Vel.X >= float64(dinoMaxSpeed) {
+		if dino.Vel.X >= tuning.Current.MaxSpeed {
 			ctrl.Delay(1)
 		} else {
 			ctrl.Delay(3)
@@ -119,6 +190,30 @@ func (dino *Sprite) AnimateRun(ctrl *carrot.Control) {
 	}
 }
 
+func (dino *Sprite) AnimateWallSlide(ctrl *carrot.Control) {
+	frames := seqiter.CreateSeqIterator(13, 15)
+	for {
+		dino.CurrentTileID = frames.Next()
+		ctrl.Delay(8)
+	}
+}
+
+func (dino *Sprite) AnimateLedgeClimb(ctrl *carrot.Control) {
+	frames := seqiter.CreateSeqIterator(10, 11, 12)
+	for {
+		dino.CurrentTileID = frames.Next()
+		ctrl.Delay(4)
+	}
+}
+
+func (dino *Sprite) AnimateClimb(ctrl *carrot.Control) {
+	frames := seqiter.CreateSeqIterator(11, 12)
+	for {
+		dino.CurrentTileID = frames.Next()
+		ctrl.Delay(8)
+	}
+}
+
 func (dino *Sprite) AnimateFly(ctrl *carrot.Control) {
 	frames := seqiter.CreateSeqIterator(17, 18)
 	for {
@@ -140,24 +235,24 @@ func (dino *Sprite) ControlTestPosition(ctrl *carrot.Control) {
 
 	for {
 
-		if inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft) {
+		if dino.Input.IsJustPressed(input.Left) {
 			dino.SetTop(rect.Top())
 			dino.SetLeft(rect.Right())
 		}
-		if inpututil.IsKeyJustPressed(ebiten.KeyArrowRight) {
+		if dino.Input.IsJustPressed(input.Right) {
 			dino.SetTop(rect.Top())
 			dino.SetRight(rect.Left())
 		}
-		if inpututil.IsKeyJustPressed(ebiten.KeyArrowUp) {
+		if dino.Input.IsJustPressed(input.Up) {
 			dino.SetTop(rect.Bottom())
 			dino.SetLeft(rect.Left())
 		}
-		if inpututil.IsKeyJustPressed(ebiten.KeyArrowDown) {
+		if dino.Input.IsJustPressed(input.Down) {
 			dino.SetBottom(rect.Top())
 			dino.SetLeft(rect.Left())
 		}
 
-		if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+		if dino.Input.IsJustPressed(input.Jump) {
 			dino.controllerScript.Transition(dino.ControllerCoroutine)
 		}
 
@@ -168,7 +263,7 @@ func (dino *Sprite) ControlTestPosition(ctrl *carrot.Control) {
 func (dino *Sprite) ControlTestFrame(ctrl *carrot.Control) {
 	dino.Actions.ClearNextApply()
 
-	if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+	if dino.Input.IsJustPressed(input.Jump) {
 		anim := dino.animations.Next()
 		dino.SetAnimation(anim)
 	}
@@ -176,10 +271,10 @@ func (dino *Sprite) ControlTestFrame(ctrl *carrot.Control) {
 	frames := seqiter.CreateSeqIterator(ids...)
 	for {
 		ctrl.Delay(1)
-		if inpututil.IsKeyJustPressed(ebiten.KeyLeft) {
+		if dino.Input.IsJustPressed(input.Left) {
 			dino.CurrentTileID = frames.Prev()
 		}
-		if inpututil.IsKeyJustPressed(ebiten.KeyRight) {
+		if dino.Input.IsJustPressed(input.Right) {
 			dino.CurrentTileID = frames.Next()
 		}
 	}
@@ -194,69 +289,253 @@ func (dino *Sprite) RestrictInWorld(common.Void) {
 }
 
 func (dino *Sprite) CollideWithTile(common.Void) {
-	level := dino.Level
-	colRect := dino.GetCollisionRect()
-	byte, l, r, t, b := level.GetTileIntersections(&colRect)
-	hit := bitf.T(byte)
+	target := dino.GetCollisionRect()
+	target.SetMidXY(dino.Pos.XY())
+
+	start := target
+	if dino.teleported {
+		start.SetMidXY(dino.Rect.MidXY())
+	} else {
+		start.SetMidXY(dino.lastRect.MidXY())
+	}
 
-	dino.Hit = hit
+	vel := vector.Sub(&target.Min, &start.Min)
+	if dino.platform != nil && !dino.teleported {
+		// Ride along with the platform it's standing on.
+		vel.Add(&dino.platform.Delta)
+	}
 
-	if hit.Some(0b1000) {
-		dino.SetLeft(l - 1)
-	} else if hit.Some(0b0100) {
-		dino.SetRight(r)
+	opts := level.SweepOptions{DropThrough: dino.dropThrough}
+	if dino.Hit.Some(0b0001) && vel.Y >= 0 {
+		// Stay on the ground when walking up and down slopes.
+		opts.StepHeight = target.Height()/2 + 1
+		opts.SnapDistance = math.Abs(vel.X) + level.ContactDistance
 	}
+	res := dino.Level.Sweep(&start, vel, opts)
+
+	dino.Hit = bitf.T(res.Hit)
+	dino.Ground = res.Ground
+	dino.platform = res.Platform
+	dino.Touch |= res.Touch
+	if res.Touch&level.FlagOneWay == 0 {
+		dino.dropThrough = false
+	}
+
+	dino.Rect = res.Rect
+	dino.Pos.Set(res.Rect.MidXY())
+	dino.lastRect = res.Rect
+}
 
-	if hit.Some(0b0010) {
-		dino.SetTop(t - 1)
-	} else if hit.Some(0b0001) {
-		dino.SetBottom(b + 1)
+// dropDown makes the dino fall through the one-way tile it's
+// standing on when down and space are pressed.
+func (dino *Sprite) dropDown() bool {
+	if dino.Ground&level.FlagOneWay == 0 ||
+		!dino.Input.IsPressed(input.Down) ||
+		!dino.Input.IsPressed(input.Jump) {
+		return false
 	}
+	dino.dropThrough = true
+	dino.Hit &^= 0b0001
+	return true
+}
+
+// ControlDead makes the dino pop up and fall off the screen after
+// touching a hazard or falling out of the world, then respawns it
+// at the last checkpoint.
+func (dino *Sprite) ControlDead(ctrl *carrot.Control) {
+	dino.setState("dead")
+	dino.Life.Die()
+	dino.SetAnimation(dino.AnimateOuchie)
+	dino.Actions.Add(dino.ApplyGravity)
+	dino.Actions.Remove(dino.CollideWithTile)
+	dino.Rotation = 0
+	dino.Vel.X = 0
+	dino.Vel.Y = -tuning.Current.DeadJumpSpeed
+	dino.Hit = 0
+
+	for i := 0; i < tuning.Current.DeadTicks; i++ {
+		ctrl.Yield()
+	}
+
+	dino.respawn()
+	ctrl.Transition(dino.ControllerCoroutine)
+	ctrl.Yield()
+}
+
+// respawn moves the dino to the last checkpoint, and undoes
+// the jump charge if the dino died before it ended.
+func (dino *Sprite) respawn() {
+	if dino.chargeSize != vector.Zero {
+		dino.DrawSize = dino.chargeSize
+		dino.chargeSize = vector.Zero
+	}
+	dino.Flip &^= 0b01
+	dino.Rotation = 0
+
+	dino.Pos = dino.Life.Checkpoint
+	dino.Vel = vector.Zero
+	dino.Hit = 0
+	dino.Ground = 0
+	dino.platform = nil
+	dino.dropThrough = false
+	dino.lastRect = dino.Rect
+	dino.lastRect.SetMidXY(dino.Pos.XY())
+}
+
+// canClimb returns true if up is pressed while the dino is in front of
+// a climbable tile, or if down is pressed while it stands on one.
+func (dino *Sprite) canClimb() bool {
+	r := dino.Rect
+	if dino.Input.IsPressed(input.Up) {
+		return dino.Level.FlagsIn(&r)&level.FlagClimbable != 0
+	}
+	if dino.Input.IsPressed(input.Down) && !dino.Input.IsPressed(input.Jump) && dino.Hit.Some(0b0001) {
+		below := rect.Create(r.Left(), r.Bottom(), r.Width(), level.ContactDistance)
+		return dino.Level.FlagsIn(&below)&level.FlagClimbable != 0
+	}
+	return false
+}
+
+// climbMove returns how far the dino climbs in the pressed directions.
+func (dino *Sprite) climbMove() vector.T {
+	var move vector.T
+	speed := tuning.Current.ClimbSpeed
+	if dino.Input.IsPressed(input.Up) {
+		move.Y = -speed
+	} else if dino.Input.IsPressed(input.Down) {
+		move.Y = speed
+	}
+	if dino.Input.IsPressed(input.Left) {
+		dino.Flip = 0b10
+		move.X = -speed
+	} else if dino.Input.IsPressed(input.Right) {
+		dino.Flip = 0b00
+		move.X = speed
+	}
+	return move
+}
+
+// climbOnTop moves the dino up onto the top of the climbable tiles,
+// given the rect where it's out of them.
+func (dino *Sprite) climbOnTop(next *rect.T) {
+	size := float64(dino.Level.RenderTileSize)
+	dino.Pos.Y = math.Ceil(next.Bottom()/size)*size - next.Height()/2
+	dino.dropThrough = false
+}
+
+// findLedge returns the ledge that the dino grabs when it falls
+// past it while pushing toward it.
+func (dino *Sprite) findLedge() (level.Ledge, bool) {
+	if dino.Vel.Y <= 0 || dino.Input.IsPressed(input.Down) {
+		return level.Ledge{}, false
+	}
+	dir := 0.0
+	if dino.Input.IsPressed(input.Left) {
+		dir = -1
+	} else if dino.Input.IsPressed(input.Right) {
+		dir = 1
+	} else {
+		return level.Ledge{}, false
+	}
+	return dino.Level.FindLedge(&dino.Rect, dir, dino.Vel.Y+tuning.Current.LedgeGrabReach)
+}
+
+// wallContact returns the side of the wall that the dino is falling
+// and pushing against, -1 for the left and 1 for the right, or 0 if
+// there's none.
+func (dino *Sprite) wallContact() float64 {
+	if dino.Hit.Some(0b0001) || dino.Vel.Y <= 0 {
+		return 0
+	}
+	if dino.Hit.Some(wallBit(-1)) && dino.Input.IsPressed(input.Left) {
+		return -1
+	}
+	if dino.Hit.Some(wallBit(1)) && dino.Input.IsPressed(input.Right) {
+		return 1
+	}
+	return 0
+}
+
+// wallBit is the Hit bit of the wall on the side of dir.
+func wallBit(dir float64) byte {
+	if dir < 0 {
+		return 0b1000
+	}
+	return 0b0100
 }
 
 func (dino *Sprite) ApplyGravity(common.Void) {
 	if dino.Hit.Some(0b0001) {
 		dino.Vel.Y = 0
 	} else {
-		dino.Vel.Y += 0.25
+		dino.Vel.Y += tuning.Current.Gravity
 
 	}
 	dino.Pos.Y += dino.Vel.Y
 }
 
 func (dino *Sprite) ControllerCoroutine(ctrl *carrot.Control) {
-	turns := 0
-	jumps := 0
-	maxJumps := 3
 	jumpCharge := 0
-
+	walkedOff := false
+	wallDir := 0.0
+	ledge := level.Ledge{}
+
+	if !dino.Actions.Has(dino.CollideWithTile) {
+		// Sweep from where the dino is now on the first collision.
+		dino.lastRect = dino.GetCollisionRect()
+		dino.lastRect.SetMidXY(dino.Pos.XY())
+	}
 	dino.Actions.Add(dino.ApplyGravity)
 	dino.Actions.Add(dino.CollideWithTile)
 
+	resume := dino.resume
+	dino.resume = ""
+	switch resume {
+	case "walk":
+		goto WALK
+	case "run":
+		goto RUN
+	case "brake":
+		goto BRAKE
+	case "fly":
+		goto FLY
+	case "fall":
+		goto FALL
+	}
+	dino.turns = 0
+	dino.jumps = 0
+
 IDLE:
 	{ // ---------------------------------------------------------
-		println("idle")
+		dino.setState("idle")
 		dino.SetAnimation(dino.AnimateIdle)
 		for {
 			walk := false
-			if ebiten.IsKeyPressed(ebiten.KeyLeft) {
+			if dino.Input.IsPressed(input.Left) {
 				dino.Flip = 0b10
 				numsign.Set(&dino.Vel.X, -1)
 				walk = true
-			} else if ebiten.IsKeyPressed(ebiten.KeyRight) {
+			} else if dino.Input.IsPressed(input.Right) {
 				dino.Flip = 0b00
 				numsign.Set(&dino.Vel.X, 1)
 				walk = true
 			}
 
 			if !dino.Hit.Some(0b0001) {
+				walkedOff = true
 				goto FALL
 			}
+			if dino.dropDown() {
+				goto FALL
+			}
+			if dino.canClimb() {
+				goto CLIMB
+			}
 
 			if walk {
 				goto WALK
 			}
-			if ebiten.IsKeyPressed(ebiten.KeySpace) {
+			if dino.Input.IsPressed(input.Jump) {
 				goto JUMP
 			}
 
@@ -266,36 +545,45 @@ IDLE:
 
 WALK:
 	{ // ---------------------------------------------------------
-		println("walk")
-		dino.Vel.X = 0.5
+		dino.setState("walk")
+		dino.Vel.X = tuning.Current.WalkStartSpeed
 		dino.SetAnimation(dino.AnimateWalk)
 		for {
 			oldSign := numsign.Get(dino.Vel.X)
-			if ebiten.IsKeyPressed(ebiten.KeyLeft) {
+			if dino.Input.IsPressed(input.Left) {
 				dino.Flip = 0b10
 				numsign.Set(&dino.Vel.X, -1)
-			} else if ebiten.IsKeyPressed(ebiten.KeyRight) {
+			} else if dino.Input.IsPressed(input.Right) {
 				dino.Flip = 0b00
 				numsign.Set(&dino.Vel.X, 1)
+			} else if dino.Ground&level.FlagSlippery != 0 && math.Abs(dino.Vel.X) > tuning.Current.BrakeStopSpeed {
+				goto BRAKE
 			} else {
 				goto IDLE
 			}
 
 			if !dino.Hit.Some(0b0001) {
+				walkedOff = true
 				goto FALL
 			}
+			if dino.dropDown() {
+				goto FALL
+			}
+			if dino.canClimb() {
+				goto CLIMB
+			}
 
 			if oldSign != numsign.Get(dino.Vel.X) {
-				dino.Vel.X *= 0.5
+				dino.Vel.X *= tuning.Current.TurnDamping
 			}
 
 			dino.Pos.X += dino.Vel.X
-			dino.Vel.X += 0.1 * numsign.Get(dino.Vel.X)
+			dino.Vel.X += tuning.Current.WalkAccel * numsign.Get(dino.Vel.X)
 
-			if ebiten.IsKeyPressed(ebiten.KeySpace) {
+			if dino.Input.IsPressed(input.Jump) {
 				goto JUMP
 			}
-			if math.Abs(dino.Vel.X) > 4.5 {
+			if math.Abs(dino.Vel.X) > tuning.Current.RunSpeed {
 				goto RUN
 			}
 
@@ -305,31 +593,38 @@ WALK:
 
 BRAKE:
 	{
-		println("brake")
+		dino.setState("brake")
 		dino.SetAnimation(dino.AnimateWalk)
 		for {
-			if ebiten.IsKeyPressed(ebiten.KeyLeft) {
+			if dino.Input.IsPressed(input.Left) {
 				dino.Flip = 0b10
-			} else if ebiten.IsKeyPressed(ebiten.KeyRight) {
+			} else if dino.Input.IsPressed(input.Right) {
 				dino.Flip = 0b00
 			}
-			if ebiten.IsKeyPressed(ebiten.KeySpace) {
+			if dino.Input.IsPressed(input.Jump) {
 				goto JUMP
 			}
-			if dino.Hit.Some(0b1100) && math.Abs(dino.Vel.X) >= float64(dinoMaxSpeed)*0.55 {
+			if dino.Hit.Some(0b1100) && math.Abs(dino.Vel.X) >= tuning.Current.BounceSpeed {
 				goto BOUNCE
 			}
-			if math.Abs(dino.Vel.X) <= 1 {
+			if math.Abs(dino.Vel.X) <= tuning.Current.BrakeStopSpeed {
 				goto IDLE
 			}
 			dino.Pos.X += dino.Vel.X
 
 			dirX := numsign.Get(dino.Vel.X)
-			if (ebiten.IsKeyPressed(ebiten.KeyLeft) && dirX > 0) ||
-				(ebiten.IsKeyPressed(ebiten.KeyRight) && dirX < 0) {
-				dino.Vel.X *= 0.90
+			slippery := dino.Ground&level.FlagSlippery != 0
+			if (dino.Input.IsPressed(input.Left) && dirX > 0) ||
+				(dino.Input.IsPressed(input.Right) && dirX < 0) {
+				if slippery {
+					dino.Vel.X *= tuning.Current.SlipperyCounterDamping
+				} else {
+					dino.Vel.X *= tuning.Current.BrakeCounterDamping
+				}
+			} else if slippery {
+				dino.Vel.X *= tuning.Current.SlipperyDamping
 			} else {
-				dino.Vel.X *= 0.97
+				dino.Vel.X *= tuning.Current.BrakeDamping
 
 			}
 
@@ -339,21 +634,21 @@ BRAKE:
 
 BOUNCE:
 	{ // ---------------------------------------------------------
-		println("bounce")
+		dino.setState("bounce")
 		dino.SetAnimation(dino.AnimateOuchie)
-		dino.Vel.X *= -0.8
-		dino.Vel.Y = -4.5
+		dino.Vel.X *= -tuning.Current.BounceRebound
+		dino.Vel.Y = -tuning.Current.BounceJumpSpeed
 
 		for {
 			dino.Pos.X += dino.Vel.X
 			dino.Pos.Y += dino.Vel.Y
-			dino.Vel.X *= 0.9
+			dino.Vel.X *= tuning.Current.BounceDamping
 
 			if dino.Vel.Y < 0 {
-				dino.Vel.Y += 0.25
+				dino.Vel.Y += tuning.Current.Gravity
 			}
 
-			if math.Abs(dino.Vel.X) < 1 {
+			if math.Abs(dino.Vel.X) < tuning.Current.BounceStopSpeed {
 				dino.Vel.Y = 0
 				dino.Vel.X = 0
 				goto IDLE
@@ -365,20 +660,27 @@ BOUNCE:
 
 RUN:
 	{ // ---------------------------------------------------------
-		println("run")
+		dino.setState("run")
 		dino.SetAnimation(dino.AnimateRun)
 		for {
 			dirX := numsign.Get(dino.Vel.X)
-			leftDown := ebiten.IsKeyPressed(ebiten.KeyLeft)
-			rightDown := ebiten.IsKeyPressed(ebiten.KeyRight)
+			leftDown := dino.Input.IsPressed(input.Left)
+			rightDown := dino.Input.IsPressed(input.Right)
 			noDown := !leftDown && !rightDown
 			brake := noDown || (leftDown && dirX == 1) || (rightDown && dirX == -1)
 
-			if ebiten.IsKeyPressed(ebiten.KeySpace) {
+			if dino.dropDown() {
+				goto FALL
+			}
+			if dino.canClimb() {
+				goto CLIMB
+			}
+			if dino.Input.IsPressed(input.Jump) {
 				goto JUMP
 			}
 
 			if !dino.Hit.Some(0b0001) {
+				walkedOff = true
 				goto FALL
 			}
 
@@ -389,19 +691,19 @@ RUN:
 			if leftDown {
 				dino.Flip = 0b10
 				numsign.Set(&dino.Vel.X, -1)
-			} else if ebiten.IsKeyPressed(ebiten.KeyRight) {
+			} else if dino.Input.IsPressed(input.Right) {
 				dino.Flip = 0b00
 				numsign.Set(&dino.Vel.X, 1)
 			}
 
-			if dino.Hit.Some(0b1100) && math.Abs(dino.Vel.X) >= float64(dinoMaxSpeed)*0.55 {
+			if dino.Hit.Some(0b1100) && math.Abs(dino.Vel.X) >= tuning.Current.BounceSpeed {
 				goto BOUNCE
 			}
 
 			dino.Pos.X += dino.Vel.X
 
-			if dino.Vel.X < float64(dinoMaxSpeed) && !dino.Hit.Some(0b1100) {
-				dino.Vel.X += 0.2 * numsign.Get(dino.Vel.X)
+			if dino.Vel.X < tuning.Current.MaxSpeed && !dino.Hit.Some(0b1100) {
+				dino.Vel.X += tuning.Current.RunAccel * numsign.Get(dino.Vel.X)
 			}
 
 			ctrl.Yield()
@@ -410,8 +712,8 @@ RUN:
 
 JUMP:
 	{ // ---------------------------------------------------------
-		println("jump")
-		jumps++
+		dino.setState("jump")
+		dino.jumps++
 		dino.Actions.Remove(dino.ApplyGravity)
 
 		dino.CurrentTileID = 11
@@ -421,22 +723,22 @@ JUMP:
 		dino.animationScript.Cancel()
 		ctrl.YieldUntil(dino.animationScript.IsDone)
 
-		dino.Vel.Y = -7.5
+		dino.Vel.Y = -tuning.Current.JumpSpeed
 		jumpCharge = 0
 
 		for {
-			leftDown := ebiten.IsKeyPressed(ebiten.KeyLeft)
-			rightDown := ebiten.IsKeyPressed(ebiten.KeyRight)
+			leftDown := dino.Input.IsPressed(input.Left)
+			rightDown := dino.Input.IsPressed(input.Right)
 
-			if math.Abs(dino.Vel.X) < float64(dinoMaxSpeed) {
+			if math.Abs(dino.Vel.X) < tuning.Current.MaxSpeed {
 				if leftDown {
 					dino.Flip = 0b10
 					numsign.Set(&dino.Vel.X, -1)
-					dino.Pos.X -= 1.0
+					dino.Pos.X -= tuning.Current.AirNudge
 				} else if rightDown {
 					dino.Flip = 0b00
 					numsign.Set(&dino.Vel.X, 1)
-					dino.Pos.X += 1.0
+					dino.Pos.X += tuning.Current.AirNudge
 				} else {
 					numsign.Set(&dino.Vel.X, 0)
 				}
@@ -445,51 +747,51 @@ JUMP:
 			dirX := numsign.Get(dino.Vel.X)
 			swerve := (leftDown && dirX == 1) || (rightDown && dirX == -1)
 			if swerve {
-				dino.Vel.X *= 0.7
+				dino.Vel.X *= tuning.Current.SwerveDamping
 			}
 
 			if !dino.Hit.Some(0b1100) {
 				dino.Pos.X += dino.Vel.X
 			}
-			if ebiten.IsKeyPressed(ebiten.KeySpace) {
-				dino.Vel.Y *= 0.95
+			if dino.Input.IsPressed(input.Jump) {
+				dino.Vel.Y *= tuning.Current.JumpHoldDamping
 			} else {
-				dino.Vel.Y *= 0.55
+				dino.Vel.Y *= tuning.Current.JumpReleaseDamping
 			}
 			dino.Pos.Y += dino.Vel.Y
 
 			jumpCharge++
-			if jumpCharge >= 40 && ebiten.IsKeyPressed(ebiten.KeyArrowUp) {
+			if jumpCharge >= tuning.Current.JumpChargeTicks && dino.Input.IsPressed(input.Up) {
 				goto JUMP_CHARGE
 			}
 
 			if dino.Hit.Some(0b0010) {
-				dino.Vel.Y *= -0.3
+				dino.Vel.Y *= -tuning.Current.CeilingRebound
 				goto FALL
 			}
 
-			if math.Abs(dino.Vel.Y) < 1 {
+			if math.Abs(dino.Vel.Y) < tuning.Current.JumpFallSpeed {
 				goto FALL
 			}
 
-			if jumps >= maxJumps && math.Abs(dino.Vel.X) >= float64(dinoMaxSpeed) {
+			if dino.jumps >= tuning.Current.MaxJumps && math.Abs(dino.Vel.X) >= tuning.Current.MaxSpeed {
 				goto FLY
-			} else if jumps >= 2 {
-				dino.Rotation += dirX * float64(jumps) / 10
+			} else if dino.jumps >= 2 {
+				dino.Rotation += dirX * float64(dino.jumps) * tuning.Current.SpinRate
 			}
 
-			if dino.Hit.Some(0b1100) && math.Abs(dino.Vel.X) >= float64(dinoMaxSpeed)*0.55 {
+			if dino.Hit.Some(0b1100) && math.Abs(dino.Vel.X) >= tuning.Current.BounceSpeed {
 				goto BOUNCE
 			}
 
-			turns++
+			dino.turns++
 			ctrl.Yield()
 		}
 	} // ---------------------------------------------------------
 
 JUMP_CHARGE:
 	{ // ---------------------------------------------------------
-		println("jump charge")
+		dino.setState("jump charge")
 		dino.Actions.Remove(dino.ApplyGravity)
 		dino.Actions.Remove(dino.CollideWithTile)
 
@@ -497,13 +799,14 @@ JUMP_CHARGE:
 		n := 0.5
 		size := dino.DrawSize
 		pos := dino.Pos
+		dino.chargeSize = size
 		decreaseStep := float64(1)
 
 		idle := 0
 		pressed := float64(0)
 
-		for pressed < 10 {
-			if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+		for pressed < float64(tuning.Current.ChargeSpins) {
+			if dino.Input.IsJustPressed(input.Jump) {
 				idle = 0
 				pressed++
 				n += 0.05
@@ -511,7 +814,7 @@ JUMP_CHARGE:
 				idle++
 			}
 
-			if idle > 150 {
+			if idle > tuning.Current.ChargeSpinIdle {
 				goto END
 			}
 			dino.Rotation += n
@@ -520,24 +823,24 @@ JUMP_CHARGE:
 		}
 
 		pressed = 0
-		for pressed < 30 {
-			if inpututil.IsKeyJustPressed(ebiten.KeyArrowDown) ||
-				inpututil.IsKeyJustPressed(ebiten.KeyArrowUp) ||
-				inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft) ||
-				inpututil.IsKeyJustPressed(ebiten.KeyArrowRight) {
+		for pressed < float64(tuning.Current.ChargeShakes) {
+			if dino.Input.IsJustPressed(input.Down) ||
+				dino.Input.IsJustPressed(input.Up) ||
+				dino.Input.IsJustPressed(input.Left) ||
+				dino.Input.IsJustPressed(input.Right) {
 				pressed++
 				idle = 0
 				dino.DrawSize.Set(size.X*(1+float64(pressed)/5), size.X*(1+float64(pressed)/5))
-				dino.Rotation += -0.1 + rand.Float64()*0.2
+				dino.Rotation += -0.1 + dino.rng.Float64()*0.2
 			} else {
 				idle++
 			}
-			if idle > 200 {
+			if idle > tuning.Current.ChargeShakeIdle {
 				goto END
 			}
 
-			dino.Pos.X += -0.2 + rand.Float64()*0.3
-			dino.Pos.Y += -0.2 + rand.Float64()*0.3
+			dino.Pos.X += -0.2 + dino.rng.Float64()*0.3
+			dino.Pos.Y += -0.2 + dino.rng.Float64()*0.3
 
 			ctrl.Yield()
 		}
@@ -561,41 +864,41 @@ JUMP_CHARGE:
 		dino.Rotation = 0
 
 		for {
-			if ebiten.IsKeyPressed(ebiten.KeyLeft) {
+			if dino.Input.IsPressed(input.Left) {
 				idle = 0
 				dino.Flip |= 0b10
-			} else if ebiten.IsKeyPressed(ebiten.KeyRight) {
+			} else if dino.Input.IsPressed(input.Right) {
 				idle = 0
 				dino.Flip &^= 0b10
 			}
-			if ebiten.IsKeyPressed(ebiten.KeyUp) {
+			if dino.Input.IsPressed(input.Up) {
 				idle = 0
 				dino.Flip &^= 0b01
-			} else if ebiten.IsKeyPressed(ebiten.KeyDown) {
+			} else if dino.Input.IsPressed(input.Down) {
 				idle = 0
 				dino.Flip |= 0b01
 			}
 
-			dino.Pos.X += -0.2 + rand.Float64()*0.3
-			dino.Pos.Y += -0.2 + rand.Float64()*0.3
+			dino.Pos.X += -0.2 + dino.rng.Float64()*0.3
+			dino.Pos.Y += -0.2 + dino.rng.Float64()*0.3
 
-			if ebiten.IsKeyPressed(ebiten.KeySpace) {
-				if ebiten.IsKeyPressed(ebiten.KeyArrowLeft) {
-					dino.Vel.X = -70
-				} else if ebiten.IsKeyPressed(ebiten.KeyArrowRight) {
-					dino.Vel.X = 70
+			if dino.Input.IsPressed(input.Jump) {
+				if dino.Input.IsPressed(input.Left) {
+					dino.Vel.X = -tuning.Current.DashSpeed
+				} else if dino.Input.IsPressed(input.Right) {
+					dino.Vel.X = tuning.Current.DashSpeed
 				}
-				if ebiten.IsKeyPressed(ebiten.KeyArrowUp) {
-					dino.Vel.Y = -70
-				} else if ebiten.IsKeyPressed(ebiten.KeyArrowDown) {
-					dino.Vel.Y = 70
+				if dino.Input.IsPressed(input.Up) {
+					dino.Vel.Y = -tuning.Current.DashSpeed
+				} else if dino.Input.IsPressed(input.Down) {
+					dino.Vel.Y = tuning.Current.DashSpeed
 				}
 
 				break
 			}
 
 			idle++
-			if idle > 100 {
+			if idle > tuning.Current.DashAimIdle {
 				goto END
 			}
 
@@ -606,8 +909,8 @@ JUMP_CHARGE:
 
 		for {
 			dino.Pos.Add(&dino.Vel)
-			dino.Vel.Scale(0.99)
-			if dino.Vel.Length() < 5 || inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+			dino.Vel.Scale(tuning.Current.DashDamping)
+			if dino.Vel.Length() < tuning.Current.DashStopSpeed || dino.Input.IsJustPressed(input.Jump) {
 				break
 			}
 			ctrl.Yield()
@@ -616,6 +919,7 @@ JUMP_CHARGE:
 	END:
 		jumpCharge = 0
 		dino.DrawSize = size
+		dino.chargeSize = vector.Zero
 		dino.Pos = pos
 		dino.Vel.Scale(0)
 		dino.Flip &^= 01
@@ -625,38 +929,38 @@ JUMP_CHARGE:
 
 FLY:
 	{ // ---------------------------------------------------------
-		println("fly")
+		dino.setState("fly")
 		dino.animationScript.Transition(dino.AnimateFly)
 		dino.Actions.Remove(dino.ApplyGravity)
 		dino.Rotation = 0
-		maxSpeed := float64(10)
-		dino.Vel.Scale(0.80)
+		dino.Vel.Scale(tuning.Current.FlyEntryDamping)
 		for {
-			if ebiten.IsKeyPressed(ebiten.KeyLeft) {
+			if dino.Input.IsPressed(input.Left) {
 				dino.Flip = 0b10
 				numsign.Set(&dino.Vel.X, -1)
-				dino.Vel.X--
-			} else if ebiten.IsKeyPressed(ebiten.KeyRight) {
+				dino.Vel.X -= tuning.Current.FlyAccel
+			} else if dino.Input.IsPressed(input.Right) {
 				dino.Flip = 0b00
 				numsign.Set(&dino.Vel.X, 1)
-				dino.Vel.X++
+				dino.Vel.X += tuning.Current.FlyAccel
 			} else {
 				numsign.Set(&dino.Vel.X, 0)
 			}
 
-			if ebiten.IsKeyPressed(ebiten.KeyUp) {
-				dino.Vel.Y += -1
-			} else if ebiten.IsKeyPressed(ebiten.KeyDown) {
-				dino.Vel.Y += 1
+			if dino.Input.IsPressed(input.Up) {
+				dino.Vel.Y -= tuning.Current.FlyAccel
+			} else if dino.Input.IsPressed(input.Down) {
+				dino.Vel.Y += tuning.Current.FlyAccel
 			}
 
-			dino.Vel.Scale(0.97)
+			dino.Vel.Scale(tuning.Current.FlyDamping)
+			maxSpeed := tuning.Current.FlyMaxSpeed
 			dino.Vel.ClampXY(-maxSpeed, -maxSpeed, maxSpeed, maxSpeed)
 
 			dino.Pos.X += dino.Vel.X
 			dino.Pos.Y += dino.Vel.Y
 
-			if ebiten.IsKeyPressed(ebiten.KeySpace) && ebiten.IsKeyPressed(ebiten.KeyArrowDown) {
+			if dino.Input.IsPressed(input.Jump) && dino.Input.IsPressed(input.Down) {
 				dino.Vel.Y = 0
 				goto FALL
 			}
@@ -666,29 +970,186 @@ FLY:
 		}
 	} // ---------------------------------------------------------
 
+CLIMB:
+	{ // ---------------------------------------------------------
+		dino.setState("climb")
+		dino.SetAnimation(dino.AnimateClimb)
+		dino.Actions.Remove(dino.ApplyGravity)
+		dino.Rotation = 0
+		dino.Vel = vector.Zero
+		dino.jumps = 0
+		// Climb down through the one-way tile on top of a ladder.
+		dino.dropThrough = true
+		dino.Hit &^= 0b0001
+		ctrl.Yield()
+
+		for {
+			if dino.Input.IsJustPressed(input.Jump) {
+				goto JUMP
+			}
+
+			move := dino.climbMove()
+			if move.Y > 0 && dino.Hit.Some(0b0001) {
+				dino.Actions.Add(dino.ApplyGravity)
+				goto IDLE
+			}
+
+			next := dino.Rect
+			next.SetMidXY(dino.Pos.X+move.X, dino.Pos.Y+move.Y)
+			if dino.Level.FlagsIn(&next)&level.FlagClimbable == 0 {
+				if move.Y < 0 {
+					dino.climbOnTop(&next)
+					dino.Actions.Add(dino.ApplyGravity)
+					goto IDLE
+				}
+				goto FALL
+			}
+
+			dino.dropThrough = true
+			dino.Pos.Add(&move)
+			ctrl.Yield()
+		}
+	} // ---------------------------------------------------------
+
+LEDGE_HANG:
+	{ // ---------------------------------------------------------
+		dino.setState("ledge hang")
+		dino.animationScript.Cancel()
+		dino.CurrentTileID = 9
+		dino.Actions.Remove(dino.ApplyGravity)
+		dino.Rotation = 0
+		dino.Vel = vector.Zero
+		dino.jumps = 0
+		dino.Pos.Y = ledge.Corner.Y + dino.Rect.Height()/2
+		ctrl.Yield()
+
+		for {
+			if dino.Input.IsPressed(input.Up) || dino.Input.IsJustPressed(input.Jump) {
+				goto LEDGE_CLIMB
+			}
+			if dino.Input.IsPressed(input.Down) {
+				goto FALL
+			}
+			ctrl.Yield()
+		}
+	} // ---------------------------------------------------------
+
+LEDGE_CLIMB:
+	{ // ---------------------------------------------------------
+		dino.setState("ledge climb")
+		dino.SetAnimation(dino.AnimateLedgeClimb)
+		ctrl.Yield()
+
+		for {
+			w, h := dino.Rect.Width(), dino.Rect.Height()
+			if dino.Pos.Y+h/2 > ledge.Corner.Y {
+				// Climb up along the wall first, then onto the ledge.
+				dino.Pos.Y = math.Max(dino.Pos.Y-tuning.Current.LedgeClimbSpeed, ledge.Corner.Y-h/2)
+			} else if (dino.Pos.X-ledge.Corner.X)*ledge.Dir < w/2 {
+				dino.Pos.X += ledge.Dir * tuning.Current.LedgeClimbSpeed
+			} else {
+				dino.Actions.Add(dino.ApplyGravity)
+				goto IDLE
+			}
+			ctrl.Yield()
+		}
+	} // ---------------------------------------------------------
+
+WALL_SLIDE:
+	{ // ---------------------------------------------------------
+		dino.setState("wall slide")
+		dino.SetAnimation(dino.AnimateWallSlide)
+		dino.Rotation = 0
+		dino.Vel.X = 0
+		dino.Vel.Y = math.Min(dino.Vel.Y, tuning.Current.WallSlideSpeed)
+		ctrl.Yield()
+
+		for {
+			if dino.Hit.Some(0b0001) {
+				dino.Vel.Y = 0
+				dino.jumps = 0
+				goto IDLE
+			}
+			if dino.Input.IsJustPressed(input.Jump) {
+				goto WALL_JUMP
+			}
+			if dino.wallContact() != wallDir {
+				goto FALL
+			}
+
+			dino.Vel.Y = math.Min(dino.Vel.Y, tuning.Current.WallSlideSpeed)
+			// Keep pushing against the wall so that it's still hit.
+			dino.Pos.X += wallDir * tuning.Current.AirNudge
+
+			ctrl.Yield()
+		}
+	} // ---------------------------------------------------------
+
+WALL_JUMP:
+	{ // ---------------------------------------------------------
+		dino.setState("wall jump")
+		dino.animationScript.Cancel()
+		dino.CurrentTileID = 10
+		dino.jumps = 1
+		dino.Vel.X = -wallDir * tuning.Current.WallJumpPush
+		dino.Vel.Y = -tuning.Current.WallJumpSpeed
+		if wallDir < 0 {
+			dino.Flip = 0b00
+		} else {
+			dino.Flip = 0b10
+		}
+		ctrl.Yield()
+
+		for {
+			dino.Pos.X += dino.Vel.X
+
+			if dino.Hit.Some(0b0010) {
+				dino.Vel.Y *= -tuning.Current.CeilingRebound
+				goto FALL
+			}
+			if dino.Vel.Y >= 0 || dino.Hit.Some(wallBit(-wallDir)) {
+				goto FALL
+			}
+
+			ctrl.Yield()
+		}
+	} // ---------------------------------------------------------
+
 FALL:
 	{ // ---------------------------------------------------------
-		println("fall")
+		dino.setState("fall")
 		dino.CurrentTileID = 12
 		dino.animationScript.Cancel()
 		dino.Actions.Add(dino.ApplyGravity)
+
+		// After walking off a ledge, the dino can still jump
+		// from the ground for a few ticks.
+		coyote := 0
+		if walkedOff {
+			walkedOff = false
+			dino.jumps = 1
+			coyote = tuning.Current.CoyoteTicks
+		}
+		// A jump that is pressed when the dino can't jump
+		// anymore is done if it lands soon enough.
+		jumpBuffer := 0
 		ctrl.Yield()
 
 		for {
-			leftDown := ebiten.IsKeyPressed(ebiten.KeyLeft)
-			rightDown := ebiten.IsKeyPressed(ebiten.KeyRight)
+			leftDown := dino.Input.IsPressed(input.Left)
+			rightDown := dino.Input.IsPressed(input.Right)
 
 			dirX := numsign.Get(dino.Vel.X)
 
-			if math.Abs(dino.Vel.X) < 4.5 {
+			if math.Abs(dino.Vel.X) < tuning.Current.RunSpeed {
 				if leftDown {
 					dino.Flip = 0b10
 					numsign.Set(&dino.Vel.X, -1)
-					dino.Pos.X -= 1.0
+					dino.Pos.X -= tuning.Current.AirNudge
 				} else if rightDown {
 					dino.Flip = 0b00
 					numsign.Set(&dino.Vel.X, 1)
-					dino.Pos.X += 1.0
+					dino.Pos.X += tuning.Current.AirNudge
 				} else {
 					numsign.Set(&dino.Vel.X, 0)
 				}
@@ -696,26 +1157,56 @@ FALL:
 
 			swerve := (leftDown && dirX == 1) || (rightDown && dirX == -1)
 			if swerve {
-				dino.Vel.X *= 0.7
+				dino.Vel.X *= tuning.Current.SwerveDamping
 			}
 
-			if jumps >= 2 {
-				dino.Rotation += dirX * float64(jumps) / 10
+			if dino.jumps >= 2 {
+				dino.Rotation += dirX * float64(dino.jumps) * tuning.Current.SpinRate
 			}
 
 			if !dino.Hit.Some(0b1100) {
 				dino.Pos.X += dino.Vel.X
 			}
 
-			if inpututil.IsKeyJustPressed(ebiten.KeySpace) && jumps < maxJumps {
-				goto JUMP
+			if dino.Input.IsJustPressed(input.Jump) {
+				if coyote > 0 {
+					dino.jumps = 0
+				}
+				if dino.jumps < tuning.Current.MaxJumps {
+					goto JUMP
+				}
+				jumpBuffer = tuning.Current.JumpBufferTicks
+			} else if jumpBuffer > 0 {
+				jumpBuffer--
+			}
+			if coyote > 0 {
+				coyote--
 			}
 
-			if dino.Hit.Some(0b0001) {
+			if dino.canClimb() {
+				goto CLIMB
+			}
+			if l, ok := dino.findLedge(); ok {
+				ledge = l
+				goto LEDGE_HANG
+			}
+			if dir := dino.wallContact(); dir != 0 {
+				wallDir = dir
+				goto WALL_SLIDE
+			}
+
+			if dino.Hit.Some(0b0001) && dino.Ground&level.FlagBouncy != 0 {
+				dino.Vel.Y = -math.Max(dino.Vel.Y, tuning.Current.BouncyTileSpeed)
+				dino.Hit &^= 0b0001
+				dino.jumps = 0
+			} else if dino.Hit.Some(0b0001) {
 				dino.Vel.Y = 0
-				jumps = 0
+				dino.jumps = 0
 				dino.Rotation = 0
-				if math.Abs(dino.Vel.X) > 4.5 {
+				if jumpBuffer > 0 {
+					goto JUMP
+				}
+				if math.Abs(dino.Vel.X) > tuning.Current.RunSpeed {
 					goto RUN
 				} else {
 					goto WALK
@@ -0,0 +1,74 @@
+package dino
+
+import (
+	"github.com/nvlled/dinojump/bitf"
+	"github.com/nvlled/dinojump/snapshot"
+)
+
+// resumableStates are the states that the controller can start from,
+// see ControllerCoroutine. The other states can't be resumed in
+// the middle of their coroutine, so a jump continues as a fall,
+// and a dead dino is respawned right away. The coyote time and
+// the jump buffer are local to the fall, so they are not saved.
+var resumableStates = []string{
+	"idle", "walk", "run", "brake", "fall", "fly",
+}
+
+func (dino *Sprite) Save() snapshot.Dino {
+	return snapshot.Dino{
+		State:    dino.state,
+		Pos:      dino.Pos,
+		Vel:      dino.Vel,
+		Flip:     dino.Flip,
+		Rotation: dino.Rotation,
+		TileID:   dino.CurrentTileID,
+
+		Gravity:   dino.Actions.Has(dino.ApplyGravity),
+		Collision: dino.Actions.Has(dino.CollideWithTile),
+
+		Hit:         byte(dino.Hit),
+		Ground:      dino.Ground,
+		DropThrough: dino.dropThrough,
+		Platform:    dino.Level.PlatformIndex(dino.platform),
+		LastRect:    dino.lastRect,
+
+		Jumps: dino.jumps,
+		Turns: dino.turns,
+
+		Checkpoint: dino.Life.Checkpoint,
+		Deaths:     dino.Life.Deaths,
+	}
+}
+
+// Restore sets the state of a newly created dino to the saved one.
+// The controller resumes the saved state on the next update,
+// and adds the actions of the state by itself.
+func (dino *Sprite) Restore(snap *snapshot.Dino) {
+	dino.resume = snapshot.Resumable(snap.State, resumableStates...)
+	dino.controllerScript.Transition(dino.ControllerCoroutine)
+
+	dino.Pos = snap.Pos
+	dino.Vel = snap.Vel
+	dino.Flip = snap.Flip
+	dino.Rotation = snap.Rotation
+	dino.CurrentTileID = snap.TileID
+
+	dino.Hit = bitf.T(snap.Hit)
+	dino.Ground = snap.Ground
+	dino.dropThrough = snap.DropThrough
+	dino.platform = dino.Level.PlatformAt(snap.Platform)
+	dino.lastRect = snap.LastRect
+	dino.Rect = snap.LastRect
+	dino.endPos = snap.Pos
+
+	dino.jumps = snap.Jumps
+	dino.turns = snap.Turns
+
+	dino.Life.Restore(snap.Checkpoint, snap.Deaths)
+
+	if snap.State == "dead" {
+		// The death is already counted, so skip to the respawn.
+		dino.respawn()
+		dino.endPos = dino.Pos
+	}
+}
@@ -5,21 +5,21 @@ import (
 	"math"
 	"math/rand"
 
-	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/nvlled/dinojump/assets"
 	"github.com/nvlled/dinojump/bitf"
 	"github.com/nvlled/dinojump/common"
+	"github.com/nvlled/dinojump/input"
 	"github.com/nvlled/dinojump/level"
+	"github.com/nvlled/dinojump/life"
 	"github.com/nvlled/dinojump/numsign"
+	"github.com/nvlled/dinojump/rect"
 	"github.com/nvlled/dinojump/seqiter"
 	"github.com/nvlled/dinojump/sprite"
+	"github.com/nvlled/dinojump/tuning"
 	"github.com/nvlled/dinojump/vector"
 )
 
-var dinoMaxSpeed float64 = 20
-
 type DinoState int
 type DinoAnimation int
 type JumpChargeState int
@@ -42,9 +42,37 @@ const (
 	DinoStateFall
 	DinoStateBounce
 	DinoStateFly
+	DinoStateWallSlide
+	DinoStateWallJump
+	DinoStateLedgeHang
+	DinoStateLedgeClimb
+	DinoStateClimb
 	DinoStateJumpCharge
+	DinoStateDead
 )
 
+var dinoStateNames = map[DinoState]string{
+	DinoStateIdle:       "idle",
+	DinoStateWalk:       "walk",
+	DinoStateRun:        "run",
+	DinoStateBrake:      "brake",
+	DinoStateJump:       "jump",
+	DinoStateFall:       "fall",
+	DinoStateBounce:     "bounce",
+	DinoStateFly:        "fly",
+	DinoStateWallSlide:  "wall slide",
+	DinoStateWallJump:   "wall jump",
+	DinoStateLedgeHang:  "ledge hang",
+	DinoStateLedgeClimb: "ledge climb",
+	DinoStateClimb:      "climb",
+	DinoStateJumpCharge: "jump charge",
+	DinoStateDead:       "dead",
+}
+
+func (state DinoState) String() string {
+	return dinoStateNames[state]
+}
+
 const (
 	JumpChargeState1 JumpChargeState = iota + 1
 	JumpChargeState2
@@ -65,6 +93,9 @@ const (
 	AnimationFall
 	AnimationBounce
 	AnimationOuchie
+	AnimationWallSlide
+	AnimationLedgeClimb
+	AnimationClimb
 )
 
 type Sprite struct {
@@ -72,7 +103,29 @@ type Sprite struct {
 
 	Hit bitf.T
 
+	// Ground are the flags of the tile the dino stands on,
+	// Touch are the flags of all the tiles it touches.
+	Ground uint16
+	Touch  uint16
+
+	dropThrough bool
+
+	// platform is the moving platform the dino stands on.
+	platform *level.Platform
+
+	// lastRect is where the collision rect was resolved in the previous
+	// tick, and endPos is where the dino was at the end of the previous tick.
+	// The dino is swept from lastRect unless it was moved from endPos
+	// outside of Update.
+	lastRect   rect.T
+	endPos     vector.T
+	teleported bool
+
 	Level *level.T
+	Input input.Source
+	Life  life.T
+
+	rng *rand.Rand
 
 	animation       DinoAnimation
 	animate         bool
@@ -86,8 +139,21 @@ type Sprite struct {
 	jumps   int
 	preJump bool
 
-	maxJumps    int
-	maxFlySpeed float64
+	// coyoteTicks is how long the dino can still jump from
+	// the ground after walking off a ledge, and jumpBuffer is
+	// how long a jump that was pressed in the air is kept
+	// until the dino lands.
+	coyoteTicks int
+	jumpBuffer  int
+
+	// wallDir is the side of the wall the dino slides on
+	// or jumps off, -1 for the left and 1 for the right.
+	wallDir float64
+
+	// ledge is the ledge the dino hangs from or climbs onto.
+	ledge level.Ledge
+
+	deadTicks int
 
 	jumpCharge      int
 	jumpChargeState JumpChargeState
@@ -103,12 +169,11 @@ func New(level *level.T) *Sprite {
 	dino := &Sprite{
 		T:     *sprite.New(img, 24, 1),
 		Level: level,
+		Input: input.Ebiten,
+		rng:   rand.New(rand.NewSource(1)),
 
 		turns: 0,
 		jumps: 0,
-
-		maxJumps:    3,
-		maxFlySpeed: 20,
 	}
 	dino.T.CollisionScale.X = 0.7
 	dino.T.CollisionScale.Y = 0.7
@@ -121,10 +186,34 @@ func New(level *level.T) *Sprite {
 }
 
 func (dino *Sprite) Update() {
+	dino.Touch = 0
+	dino.teleported = dino.Pos != dino.endPos
 	dino.T.Update()
+	if dino.state != DinoStateDead {
+		dino.Life.Update(dino.Level, dino.Pos, &dino.Rect)
+		if dino.Touch&level.FlagHazard != 0 || life.OutOfWorld(dino.Level, &dino.Rect) {
+			dino.transition(DinoStateDead)
+		}
+	}
 	dino.updateController()
 	dino.updateAnimation()
+	dino.endPos = dino.Pos
+}
+
+func (dino *Sprite) SetLevel(level *level.T) {
+	dino.Level = level
+}
+
+func (dino *Sprite) SetInput(source input.Source) {
+	dino.Input = source
+}
 
+func (dino *Sprite) GetLife() *life.T {
+	return &dino.Life
+}
+
+func (dino *Sprite) StateName() string {
+	return dino.state.String()
 }
 
 func (dino *Sprite) updateAnimation() {
@@ -135,7 +224,7 @@ func (dino *Sprite) updateAnimation() {
 	dino.CurrentTileID = dino.animationFrames.Next()
 
 	if dino.animation == AnimationRun {
-		if dino.Vel.X >= float64(dinoMaxSpeed) {
+		if dino.Vel.X >= tuning.Current.MaxSpeed {
 			dino.animationDelay = 1
 		} else {
 			dino.animationDelay = 3
@@ -161,8 +250,20 @@ func (dino *Sprite) updateController() {
 		dino.updateJumpCharge()
 	case DinoStateFly:
 		dino.updateFly()
+	case DinoStateWallSlide:
+		dino.updateWallSlide()
+	case DinoStateWallJump:
+		dino.updateWallJump()
+	case DinoStateLedgeHang:
+		dino.updateLedgeHang()
+	case DinoStateLedgeClimb:
+		dino.updateLedgeClimb()
+	case DinoStateClimb:
+		dino.updateClimb()
 	case DinoStateFall:
 		dino.updateFall()
+	case DinoStateDead:
+		dino.updateDead()
 	}
 }
 
@@ -184,6 +285,15 @@ func (dino *Sprite) SetAnimation(animation DinoAnimation) {
 	case AnimationRun:
 		dino.animationFrames = seqiter.CreateSeqIterator(common.RangeSlice(18, 23)...)
 		dino.animationDelay = 3
+	case AnimationWallSlide:
+		dino.animationFrames = seqiter.CreateSeqIterator(13, 15)
+		dino.animationDelay = 8
+	case AnimationLedgeClimb:
+		dino.animationFrames = seqiter.CreateSeqIterator(10, 11, 12)
+		dino.animationDelay = 4
+	case AnimationClimb:
+		dino.animationFrames = seqiter.CreateSeqIterator(11, 12)
+		dino.animationDelay = 8
 	case AnimationFly:
 		dino.animationFrames = seqiter.CreateSeqIterator(17, 18)
 		dino.animationDelay = 10
@@ -200,31 +310,143 @@ func (dino *Sprite) RestrictInWorld(common.Void) {
 }
 
 func (dino *Sprite) CollideWithTile(common.Void) {
-	level := dino.Level
-	colRect := dino.GetCollisionRect()
-	byte, l, r, t, b := level.GetTileIntersections(&colRect)
-	hit := bitf.T(byte)
+	target := dino.GetCollisionRect()
+	target.SetMidXY(dino.Pos.XY())
+
+	start := target
+	if dino.teleported {
+		start.SetMidXY(dino.Rect.MidXY())
+	} else {
+		start.SetMidXY(dino.lastRect.MidXY())
+	}
+
+	vel := vector.Sub(&target.Min, &start.Min)
+	if dino.platform != nil && !dino.teleported {
+		// Ride along with the platform it's standing on.
+		vel.Add(&dino.platform.Delta)
+	}
+
+	opts := level.SweepOptions{DropThrough: dino.dropThrough}
+	if dino.Hit.Some(0b0001) && vel.Y >= 0 {
+		// Stay on the ground when walking up and down slopes.
+		opts.StepHeight = target.Height()/2 + 1
+		opts.SnapDistance = math.Abs(vel.X) + level.ContactDistance
+	}
+	res := dino.Level.Sweep(&start, vel, opts)
+
+	dino.Hit = bitf.T(res.Hit)
+	dino.Ground = res.Ground
+	dino.platform = res.Platform
+	dino.Touch |= res.Touch
+	if res.Touch&level.FlagOneWay == 0 {
+		dino.dropThrough = false
+	}
 
-	dino.Hit = hit
+	dino.Rect = res.Rect
+	dino.Pos.Set(res.Rect.MidXY())
+	dino.lastRect = res.Rect
+}
 
-	if hit.Some(0b1000) {
-		dino.SetLeft(l - 1)
-	} else if hit.Some(0b0100) {
-		dino.SetRight(r)
+// dropDown makes the dino fall through the one-way tile it's
+// standing on when down and space are pressed.
+func (dino *Sprite) dropDown() bool {
+	if dino.Ground&level.FlagOneWay == 0 ||
+		!dino.Input.IsPressed(input.Down) ||
+		!dino.Input.IsPressed(input.Jump) {
+		return false
+	}
+	dino.dropThrough = true
+	dino.Hit &^= 0b0001
+	return true
+}
+
+// canClimb returns true if up is pressed while the dino is in front of
+// a climbable tile, or if down is pressed while it stands on one.
+func (dino *Sprite) canClimb() bool {
+	r := dino.Rect
+	if dino.Input.IsPressed(input.Up) {
+		return dino.Level.FlagsIn(&r)&level.FlagClimbable != 0
+	}
+	if dino.Input.IsPressed(input.Down) && !dino.Input.IsPressed(input.Jump) && dino.Hit.Some(0b0001) {
+		below := rect.Create(r.Left(), r.Bottom(), r.Width(), level.ContactDistance)
+		return dino.Level.FlagsIn(&below)&level.FlagClimbable != 0
 	}
+	return false
+}
 
-	if hit.Some(0b0010) {
-		dino.SetTop(t - 1)
-	} else if hit.Some(0b0001) {
-		dino.SetBottom(b + 1)
+// climbMove returns how far the dino climbs in the pressed directions.
+func (dino *Sprite) climbMove() vector.T {
+	var move vector.T
+	speed := tuning.Current.ClimbSpeed
+	if dino.Input.IsPressed(input.Up) {
+		move.Y = -speed
+	} else if dino.Input.IsPressed(input.Down) {
+		move.Y = speed
 	}
+	if dino.Input.IsPressed(input.Left) {
+		dino.Flip = 0b10
+		move.X = -speed
+	} else if dino.Input.IsPressed(input.Right) {
+		dino.Flip = 0b00
+		move.X = speed
+	}
+	return move
+}
+
+// climbOnTop moves the dino up onto the top of the climbable tiles,
+// given the rect where it's out of them.
+func (dino *Sprite) climbOnTop(next *rect.T) {
+	size := float64(dino.Level.RenderTileSize)
+	dino.Pos.Y = math.Ceil(next.Bottom()/size)*size - next.Height()/2
+	dino.dropThrough = false
+}
+
+// findLedge returns the ledge that the dino grabs when it falls
+// past it while pushing toward it.
+func (dino *Sprite) findLedge() (level.Ledge, bool) {
+	if dino.Vel.Y <= 0 || dino.Input.IsPressed(input.Down) {
+		return level.Ledge{}, false
+	}
+	dir := 0.0
+	if dino.Input.IsPressed(input.Left) {
+		dir = -1
+	} else if dino.Input.IsPressed(input.Right) {
+		dir = 1
+	} else {
+		return level.Ledge{}, false
+	}
+	return dino.Level.FindLedge(&dino.Rect, dir, dino.Vel.Y+tuning.Current.LedgeGrabReach)
+}
+
+// wallContact returns the side of the wall that the dino is falling
+// and pushing against, -1 for the left and 1 for the right, or 0 if
+// there's none.
+func (dino *Sprite) wallContact() float64 {
+	if dino.Hit.Some(0b0001) || dino.Vel.Y <= 0 {
+		return 0
+	}
+	if dino.Hit.Some(wallBit(-1)) && dino.Input.IsPressed(input.Left) {
+		return -1
+	}
+	if dino.Hit.Some(wallBit(1)) && dino.Input.IsPressed(input.Right) {
+		return 1
+	}
+	return 0
+}
+
+// wallBit is the Hit bit of the wall on the side of dir.
+func wallBit(dir float64) byte {
+	if dir < 0 {
+		return 0b1000
+	}
+	return 0b0100
 }
 
 func (dino *Sprite) ApplyGravity(common.Void) {
 	if dino.Hit.Some(0b0001) {
 		dino.Vel.Y = 0
 	} else {
-		dino.Vel.Y += 0.5
+		dino.Vel.Y += tuning.Current.Gravity
 
 	}
 	dino.Pos.Y += dino.Vel.Y
@@ -237,7 +459,7 @@ func (dino *Sprite) transition(nextState DinoState) DinoState {
 		dino.SetAnimation(AnimationIdle)
 	case DinoStateWalk:
 		println("walk")
-		dino.Vel.X = 0.5
+		dino.Vel.X = tuning.Current.WalkStartSpeed
 		dino.SetAnimation(AnimationWalk)
 	case DinoStateBrake:
 		println("brake")
@@ -245,8 +467,8 @@ func (dino *Sprite) transition(nextState DinoState) DinoState {
 	case DinoStateBounce:
 		println("bounce")
 		dino.SetAnimation(AnimationOuchie)
-		dino.Vel.X *= -0.8
-		dino.Vel.Y = -4.5
+		dino.Vel.X *= -tuning.Current.BounceRebound
+		dino.Vel.Y = -tuning.Current.BounceJumpSpeed
 	case DinoStateRun:
 		println("run")
 		dino.SetAnimation(AnimationRun)
@@ -277,38 +499,106 @@ func (dino *Sprite) transition(nextState DinoState) DinoState {
 		dino.SetAnimation(AnimationNone)
 		dino.Actions.Remove(dino.ApplyGravity)
 		dino.Rotation = 0
-		dino.Vel.Scale(0.80)
+		dino.Vel.Scale(tuning.Current.FlyEntryDamping)
+	case DinoStateWallSlide:
+		println("wall slide")
+		dino.SetAnimation(AnimationWallSlide)
+		dino.Rotation = 0
+		dino.Vel.X = 0
+		dino.Vel.Y = math.Min(dino.Vel.Y, tuning.Current.WallSlideSpeed)
+	case DinoStateWallJump:
+		println("wall jump")
+		dino.SetAnimation(AnimationNone)
+		dino.CurrentTileID = 10
+		dino.jumps = 1
+		dino.Vel.X = -dino.wallDir * tuning.Current.WallJumpPush
+		dino.Vel.Y = -tuning.Current.WallJumpSpeed
+		if dino.wallDir < 0 {
+			dino.Flip = 0b00
+		} else {
+			dino.Flip = 0b10
+		}
+	case DinoStateLedgeHang:
+		println("ledge hang")
+		dino.SetAnimation(AnimationNone)
+		dino.CurrentTileID = 9
+		dino.Actions.Remove(dino.ApplyGravity)
+		dino.Rotation = 0
+		dino.Vel = vector.Zero
+		dino.jumps = 0
+		dino.Pos.Y = dino.ledge.Corner.Y + dino.Rect.Height()/2
+	case DinoStateLedgeClimb:
+		println("ledge climb")
+		dino.SetAnimation(AnimationLedgeClimb)
+	case DinoStateClimb:
+		println("climb")
+		dino.SetAnimation(AnimationClimb)
+		dino.Actions.Remove(dino.ApplyGravity)
+		dino.Rotation = 0
+		dino.Vel = vector.Zero
+		dino.jumps = 0
+		// Climb down through the one-way tile on top of a ladder.
+		dino.dropThrough = true
+		dino.Hit &^= 0b0001
+	case DinoStateDead:
+		println("dead")
+		dino.Life.Die()
+		dino.SetAnimation(AnimationOuchie)
+		dino.Actions.Add(dino.ApplyGravity)
+		dino.Actions.Remove(dino.CollideWithTile)
+		dino.Rotation = 0
+		dino.Vel.X = 0
+		dino.Vel.Y = -tuning.Current.DeadJumpSpeed
+		dino.Hit = 0
+		dino.deadTicks = 0
 	case DinoStateFall:
 		println("fall")
 		dino.CurrentTileID = 12
 		dino.SetAnimation(AnimationNone)
 		dino.Actions.Add(dino.ApplyGravity)
+		dino.coyoteTicks = 0
+		dino.jumpBuffer = 0
 	}
 
 	dino.state = nextState
 	return nextState
 }
 
+// walkOff makes the dino fall after walking off a ledge,
+// which uses up its jump from the ground after a few ticks.
+func (dino *Sprite) walkOff() DinoState {
+	dino.transition(DinoStateFall)
+	dino.jumps = 1
+	dino.coyoteTicks = tuning.Current.CoyoteTicks
+	return DinoStateFall
+}
+
 func (dino *Sprite) updateIdle() DinoState {
 	walk := false
-	if ebiten.IsKeyPressed(ebiten.KeyLeft) {
+	if dino.Input.IsPressed(input.Left) {
 		dino.Flip = 0b10
 		numsign.Set(&dino.Vel.X, -1)
 		walk = true
-	} else if ebiten.IsKeyPressed(ebiten.KeyRight) {
+	} else if dino.Input.IsPressed(input.Right) {
 		dino.Flip = 0b00
 		numsign.Set(&dino.Vel.X, 1)
 		walk = true
 	}
 
 	if !dino.Hit.Some(0b0001) {
+		return dino.walkOff()
+	}
+	if dino.dropDown() {
 		return dino.transition(DinoStateFall)
 	}
+	if dino.canClimb() {
+		return dino.transition(DinoStateClimb)
+	}
 
 	if walk {
 		return dino.transition(DinoStateWalk)
 	}
-	if ebiten.IsKeyPressed(ebiten.KeySpace) {
+	if dino.Input.IsPressed(input.Jump) {
 		return dino.transition(DinoStateJump)
 	}
 
@@ -317,31 +607,39 @@ func (dino *Sprite) updateIdle() DinoState {
 
 func (dino *Sprite) updateWalk() DinoState {
 	oldDir := numsign.Get(dino.Vel.X)
-	if ebiten.IsKeyPressed(ebiten.KeyLeft) {
+	if dino.Input.IsPressed(input.Left) {
 		dino.Flip = 0b10
 		numsign.Set(&dino.Vel.X, -1)
-	} else if ebiten.IsKeyPressed(ebiten.KeyRight) {
+	} else if dino.Input.IsPressed(input.Right) {
 		dino.Flip = 0b00
 		numsign.Set(&dino.Vel.X, 1)
+	} else if dino.Ground&level.FlagSlippery != 0 && math.Abs(dino.Vel.X) > tuning.Current.BrakeStopSpeed {
+		return dino.transition(DinoStateBrake)
 	} else {
 		return dino.transition(DinoStateIdle)
 	}
 
 	if !dino.Hit.Some(0b0001) {
+		return dino.walkOff()
+	}
+	if dino.dropDown() {
 		return dino.transition(DinoStateFall)
 	}
+	if dino.canClimb() {
+		return dino.transition(DinoStateClimb)
+	}
 
 	if oldDir != numsign.Get(dino.Vel.X) {
-		dino.Vel.X *= 0.5
+		dino.Vel.X *= tuning.Current.TurnDamping
 	}
 
 	dino.Pos.X += dino.Vel.X
-	dino.Vel.X += 0.1 * numsign.Get(dino.Vel.X)
+	dino.Vel.X += tuning.Current.WalkAccel * numsign.Get(dino.Vel.X)
 
-	if ebiten.IsKeyPressed(ebiten.KeySpace) {
+	if dino.Input.IsPressed(input.Jump) {
 		return dino.transition(DinoStateJump)
 	}
-	if math.Abs(dino.Vel.X) > 4.5 {
+	if math.Abs(dino.Vel.X) > tuning.Current.RunSpeed {
 		return dino.transition(DinoStateRun)
 	}
 
@@ -349,28 +647,35 @@ func (dino *Sprite) updateWalk() DinoState {
 }
 
 func (dino *Sprite) updateBrake() DinoState {
-	if ebiten.IsKeyPressed(ebiten.KeyLeft) {
+	if dino.Input.IsPressed(input.Left) {
 		dino.Flip = 0b10
-	} else if ebiten.IsKeyPressed(ebiten.KeyRight) {
+	} else if dino.Input.IsPressed(input.Right) {
 		dino.Flip = 0b00
 	}
-	if ebiten.IsKeyPressed(ebiten.KeySpace) {
+	if dino.Input.IsPressed(input.Jump) {
 		return dino.transition(DinoStateJump)
 	}
-	if dino.Hit.Some(0b1100) && math.Abs(dino.Vel.X) >= float64(dinoMaxSpeed)*0.55 {
+	if dino.Hit.Some(0b1100) && math.Abs(dino.Vel.X) >= tuning.Current.BounceSpeed {
 		return dino.transition(DinoStateBounce)
 	}
-	if math.Abs(dino.Vel.X) <= 1 {
+	if math.Abs(dino.Vel.X) <= tuning.Current.BrakeStopSpeed {
 		return dino.transition(DinoStateIdle)
 	}
 	dino.Pos.X += dino.Vel.X
 
 	dirX := numsign.Get(dino.Vel.X)
-	if (ebiten.IsKeyPressed(ebiten.KeyLeft) && dirX > 0) ||
-		(ebiten.IsKeyPressed(ebiten.KeyRight) && dirX < 0) {
-		dino.Vel.X *= 0.90
+	slippery := dino.Ground&level.FlagSlippery != 0
+	if (dino.Input.IsPressed(input.Left) && dirX > 0) ||
+		(dino.Input.IsPressed(input.Right) && dirX < 0) {
+		if slippery {
+			dino.Vel.X *= tuning.Current.SlipperyCounterDamping
+		} else {
+			dino.Vel.X *= tuning.Current.BrakeCounterDamping
+		}
+	} else if slippery {
+		dino.Vel.X *= tuning.Current.SlipperyDamping
 	} else {
-		dino.Vel.X *= 0.97
+		dino.Vel.X *= tuning.Current.BrakeDamping
 
 	}
 
@@ -380,13 +685,13 @@ func (dino *Sprite) updateBrake() DinoState {
 func (dino *Sprite) updateBounce() DinoState {
 	dino.Pos.X += dino.Vel.X
 	dino.Pos.Y += dino.Vel.Y
-	dino.Vel.X *= 0.9
+	dino.Vel.X *= tuning.Current.BounceDamping
 
 	if dino.Vel.Y < 0 {
-		dino.Vel.Y += 0.25
+		dino.Vel.Y += tuning.Current.Gravity
 	}
 
-	if math.Abs(dino.Vel.X) < 1 {
+	if math.Abs(dino.Vel.X) < tuning.Current.BounceStopSpeed {
 		dino.Vel.Y = 0
 		dino.Vel.X = 0
 		return dino.transition(DinoStateIdle)
@@ -396,18 +701,24 @@ func (dino *Sprite) updateBounce() DinoState {
 }
 
 func (dino *Sprite) updateRun() DinoState {
-	leftDown := ebiten.IsKeyPressed(ebiten.KeyLeft)
-	rightDown := ebiten.IsKeyPressed(ebiten.KeyRight)
+	leftDown := dino.Input.IsPressed(input.Left)
+	rightDown := dino.Input.IsPressed(input.Right)
 	noDown := !leftDown && !rightDown
 	dirX := numsign.Get(dino.Vel.X)
 	brake := noDown || (leftDown && dirX == 1) || (rightDown && dirX == -1)
 
-	if ebiten.IsKeyPressed(ebiten.KeySpace) {
+	if dino.dropDown() {
+		return dino.transition(DinoStateFall)
+	}
+	if dino.canClimb() {
+		return dino.transition(DinoStateClimb)
+	}
+	if dino.Input.IsPressed(input.Jump) {
 		return dino.transition(DinoStateJump)
 	}
 
 	if !dino.Hit.Some(0b0001) {
-		return dino.transition(DinoStateFall)
+		return dino.walkOff()
 	}
 
 	if brake {
@@ -417,19 +728,19 @@ func (dino *Sprite) updateRun() DinoState {
 	if leftDown {
 		dino.Flip = 0b10
 		numsign.Set(&dino.Vel.X, -1)
-	} else if ebiten.IsKeyPressed(ebiten.KeyRight) {
+	} else if dino.Input.IsPressed(input.Right) {
 		dino.Flip = 0b00
 		numsign.Set(&dino.Vel.X, 1)
 	}
 
-	if dino.Hit.Some(0b1100) && math.Abs(dino.Vel.X) >= float64(dinoMaxSpeed)*0.55 {
+	if dino.Hit.Some(0b1100) && math.Abs(dino.Vel.X) >= tuning.Current.BounceSpeed {
 		return dino.transition(DinoStateBounce)
 	}
 
 	dino.Pos.X += dino.Vel.X
 
-	if dino.Vel.X < float64(dinoMaxSpeed) && !dino.Hit.Some(0b1100) {
-		dino.Vel.X += 0.2 * numsign.Get(dino.Vel.X)
+	if dino.Vel.X < tuning.Current.MaxSpeed && !dino.Hit.Some(0b1100) {
+		dino.Vel.X += tuning.Current.RunAccel * numsign.Get(dino.Vel.X)
 	}
 
 	return 0
@@ -438,16 +749,16 @@ func (dino *Sprite) updateRun() DinoState {
 func (dino *Sprite) updateJump() DinoState {
 	if dino.preJump {
 		dino.preJump = false
-		dino.Vel.Y = -7.5
+		dino.Vel.Y = -tuning.Current.JumpSpeed
 		dino.jumpCharge = 0
 		return 0
 	}
 
-	leftDown := ebiten.IsKeyPressed(ebiten.KeyLeft)
-	rightDown := ebiten.IsKeyPressed(ebiten.KeyRight)
+	leftDown := dino.Input.IsPressed(input.Left)
+	rightDown := dino.Input.IsPressed(input.Right)
 
 	dirX := numsign.Get(dino.Vel.X)
-	if math.Abs(dino.Vel.X) < float64(dinoMaxSpeed) {
+	if math.Abs(dino.Vel.X) < tuning.Current.MaxSpeed {
 		if leftDown {
 			dino.Flip = 0b10
 			numsign.Set(&dino.Vel.X, -1)
@@ -460,40 +771,40 @@ func (dino *Sprite) updateJump() DinoState {
 	}
 	swerve := (leftDown && dirX == 1) || (rightDown && dirX == -1)
 	if swerve {
-		dino.Vel.X *= 0.7
+		dino.Vel.X *= tuning.Current.SwerveDamping
 	}
 
 	if !dino.Hit.Some(0b1100) {
 		dino.Pos.X += dino.Vel.X
 	}
-	if ebiten.IsKeyPressed(ebiten.KeySpace) {
-		dino.Vel.Y *= 0.95
+	if dino.Input.IsPressed(input.Jump) {
+		dino.Vel.Y *= tuning.Current.JumpHoldDamping
 	} else {
-		dino.Vel.Y *= 0.55
+		dino.Vel.Y *= tuning.Current.JumpReleaseDamping
 	}
 	dino.Pos.Y += dino.Vel.Y
 
 	dino.jumpCharge++
-	if dino.jumpCharge >= 40 && ebiten.IsKeyPressed(ebiten.KeyArrowUp) {
+	if dino.jumpCharge >= tuning.Current.JumpChargeTicks && dino.Input.IsPressed(input.Up) {
 		return dino.transition(DinoStateJumpCharge)
 	}
 
 	if dino.Hit.Some(0b0010) {
-		dino.Vel.Y *= -0.3
+		dino.Vel.Y *= -tuning.Current.CeilingRebound
 		return dino.transition(DinoStateFall)
 	}
 
-	if math.Abs(dino.Vel.Y) < 1 {
+	if math.Abs(dino.Vel.Y) < tuning.Current.JumpFallSpeed {
 		return dino.transition(DinoStateFall)
 	}
 
-	if dino.jumps >= dino.maxJumps && math.Abs(dino.Vel.X) >= float64(dinoMaxSpeed) {
+	if dino.jumps >= tuning.Current.MaxJumps && math.Abs(dino.Vel.X) >= tuning.Current.MaxSpeed {
 		return dino.transition(DinoStateFly)
 	} else if dino.jumps >= 2 {
-		dino.Rotation += dirX * float64(dino.jumps) / 10
+		dino.Rotation += dirX * float64(dino.jumps) * tuning.Current.SpinRate
 	}
 
-	if dino.Hit.Some(0b1100) && math.Abs(dino.Vel.X) >= float64(dinoMaxSpeed)*0.55 {
+	if dino.Hit.Some(0b1100) && math.Abs(dino.Vel.X) >= tuning.Current.BounceSpeed {
 		return dino.transition(DinoStateBounce)
 	}
 
@@ -507,11 +818,11 @@ func (dino *Sprite) updateJumpCharge() DinoState {
 	switch dino.jumpChargeState {
 	case JumpChargeState1:
 		{
-			if data.pressed >= 10 {
+			if data.pressed >= float64(tuning.Current.ChargeSpins) {
 				dino.jumpChargeState = JumpChargeState2
 				return dino.state
 			}
-			if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+			if dino.Input.IsJustPressed(input.Jump) {
 				data.idle = 0
 				data.pressed++
 				data.n += 0.05
@@ -519,7 +830,7 @@ func (dino *Sprite) updateJumpCharge() DinoState {
 				data.idle++
 			}
 
-			if data.idle > 150 {
+			if data.idle > tuning.Current.ChargeSpinIdle {
 				data.pressed = 0
 				dino.jumpChargeState = JumpChargeStateEnd
 				return dino.state
@@ -529,28 +840,28 @@ func (dino *Sprite) updateJumpCharge() DinoState {
 
 	case JumpChargeState2:
 		{
-			if data.pressed >= 30 {
+			if data.pressed >= float64(tuning.Current.ChargeShakes) {
 				dino.jumpChargeState = JumpChargeState3
 				return dino.state
 			}
-			if inpututil.IsKeyJustPressed(ebiten.KeyArrowDown) ||
-				inpututil.IsKeyJustPressed(ebiten.KeyArrowUp) ||
-				inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft) ||
-				inpututil.IsKeyJustPressed(ebiten.KeyArrowRight) {
+			if dino.Input.IsJustPressed(input.Down) ||
+				dino.Input.IsJustPressed(input.Up) ||
+				dino.Input.IsJustPressed(input.Left) ||
+				dino.Input.IsJustPressed(input.Right) {
 				data.pressed++
 				data.idle = 0
 				dino.DrawSize.Set(data.size.X*(1+float64(data.pressed)/5), data.size.X*(1+float64(data.pressed)/5))
-				dino.Rotation += -0.1 + rand.Float64()*0.2
+				dino.Rotation += -0.1 + dino.rng.Float64()*0.2
 			} else {
 				data.idle++
 			}
-			if data.idle > 200 {
+			if data.idle > tuning.Current.ChargeShakeIdle {
 				dino.jumpChargeState = JumpChargeStateEnd
 				return dino.state
 			}
 
-			dino.Pos.X += -0.2 + rand.Float64()*0.3
-			dino.Pos.Y += -0.2 + rand.Float64()*0.3
+			dino.Pos.X += -0.2 + dino.rng.Float64()*0.3
+			dino.Pos.Y += -0.2 + dino.rng.Float64()*0.3
 		}
 
 	case JumpChargeState3:
@@ -572,34 +883,34 @@ func (dino *Sprite) updateJumpCharge() DinoState {
 		}
 	case JumpChargeState4:
 		{
-			if ebiten.IsKeyPressed(ebiten.KeyLeft) {
+			if dino.Input.IsPressed(input.Left) {
 				data.idle = 0
 				dino.Flip |= 0b10
-			} else if ebiten.IsKeyPressed(ebiten.KeyRight) {
+			} else if dino.Input.IsPressed(input.Right) {
 				data.idle = 0
 				dino.Flip &^= 0b10
 			}
-			if ebiten.IsKeyPressed(ebiten.KeyUp) {
+			if dino.Input.IsPressed(input.Up) {
 				data.idle = 0
 				dino.Flip &^= 0b01
-			} else if ebiten.IsKeyPressed(ebiten.KeyDown) {
+			} else if dino.Input.IsPressed(input.Down) {
 				data.idle = 0
 				dino.Flip |= 0b01
 			}
 
-			dino.Pos.X += -0.2 + rand.Float64()*0.3
-			dino.Pos.Y += -0.2 + rand.Float64()*0.3
+			dino.Pos.X += -0.2 + dino.rng.Float64()*0.3
+			dino.Pos.Y += -0.2 + dino.rng.Float64()*0.3
 
-			if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
-				if ebiten.IsKeyPressed(ebiten.KeyArrowLeft) {
-					dino.Vel.X = -70
-				} else if ebiten.IsKeyPressed(ebiten.KeyArrowRight) {
-					dino.Vel.X = 70
+			if dino.Input.IsJustPressed(input.Jump) {
+				if dino.Input.IsPressed(input.Left) {
+					dino.Vel.X = -tuning.Current.DashSpeed
+				} else if dino.Input.IsPressed(input.Right) {
+					dino.Vel.X = tuning.Current.DashSpeed
 				}
-				if ebiten.IsKeyPressed(ebiten.KeyArrowUp) {
-					dino.Vel.Y = -70
-				} else if ebiten.IsKeyPressed(ebiten.KeyArrowDown) {
-					dino.Vel.Y = 70
+				if dino.Input.IsPressed(input.Up) {
+					dino.Vel.Y = -tuning.Current.DashSpeed
+				} else if dino.Input.IsPressed(input.Down) {
+					dino.Vel.Y = tuning.Current.DashSpeed
 				}
 
 				dino.jumpChargeState = JumpChargeState5
@@ -607,7 +918,7 @@ func (dino *Sprite) updateJumpCharge() DinoState {
 			}
 
 			data.idle++
-			if data.idle > 100 {
+			if data.idle > tuning.Current.DashAimIdle {
 				dino.jumpChargeState = JumpChargeStateEnd
 				return dino.state
 			}
@@ -616,8 +927,8 @@ func (dino *Sprite) updateJumpCharge() DinoState {
 	case JumpChargeState5:
 		{
 			dino.Pos.Add(&dino.Vel)
-			dino.Vel.Scale(0.99)
-			if dino.Vel.Length() < 5 || inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+			dino.Vel.Scale(tuning.Current.DashDamping)
+			if dino.Vel.Length() < tuning.Current.DashStopSpeed || dino.Input.IsJustPressed(input.Jump) {
 				dino.jumpChargeState = JumpChargeStateEnd
 				return dino.state
 			}
@@ -637,28 +948,29 @@ func (dino *Sprite) updateJumpCharge() DinoState {
 }
 
 func (dino *Sprite) updateFly() DinoState {
-	if ebiten.IsKeyPressed(ebiten.KeyLeft) {
+	if dino.Input.IsPressed(input.Left) {
 		numsign.Set(&dino.Vel.X, -1)
 		dino.Flip = 0b10
-		dino.Vel.X--
-	} else if ebiten.IsKeyPressed(ebiten.KeyRight) {
+		dino.Vel.X -= tuning.Current.FlyAccel
+	} else if dino.Input.IsPressed(input.Right) {
 		numsign.Set(&dino.Vel.X, 1)
 		dino.Flip = 0b00
-		dino.Vel.X++
+		dino.Vel.X += tuning.Current.FlyAccel
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyUp) {
-		dino.Vel.Y += -1
-	} else if ebiten.IsKeyPressed(ebiten.KeyDown) {
-		dino.Vel.Y += 1
+	if dino.Input.IsPressed(input.Up) {
+		dino.Vel.Y -= tuning.Current.FlyAccel
+	} else if dino.Input.IsPressed(input.Down) {
+		dino.Vel.Y += tuning.Current.FlyAccel
 	}
 
-	dino.Vel.Scale(0.97)
-	dino.Vel.ClampXY(-dinoMaxSpeed, -dinoMaxSpeed, dinoMaxSpeed, dinoMaxSpeed)
+	dino.Vel.Scale(tuning.Current.FlyDamping)
+	maxSpeed := tuning.Current.FlyMaxSpeed
+	dino.Vel.ClampXY(-maxSpeed, -maxSpeed, maxSpeed, maxSpeed)
 
 	dino.Pos.X += dino.Vel.X
 	dino.Pos.Y += dino.Vel.Y
 
-	if ebiten.IsKeyPressed(ebiten.KeySpace) && ebiten.IsKeyPressed(ebiten.KeyArrowDown) {
+	if dino.Input.IsPressed(input.Jump) && dino.Input.IsPressed(input.Down) {
 		dino.Vel.Y = 0
 		return dino.transition(DinoStateFall)
 	}
@@ -667,11 +979,11 @@ func (dino *Sprite) updateFly() DinoState {
 }
 
 func (dino *Sprite) updateFall() DinoState {
-	leftDown := ebiten.IsKeyPressed(ebiten.KeyLeft)
-	rightDown := ebiten.IsKeyPressed(ebiten.KeyRight)
+	leftDown := dino.Input.IsPressed(input.Left)
+	rightDown := dino.Input.IsPressed(input.Right)
 
 	dirX := numsign.Get(dino.Vel.X)
-	if math.Abs(dino.Vel.X) < 4.5 {
+	if math.Abs(dino.Vel.X) < tuning.Current.RunSpeed {
 		if leftDown {
 			dino.Flip = 0b10
 			numsign.Set(&dino.Vel.X, -1)
@@ -685,26 +997,59 @@ func (dino *Sprite) updateFall() DinoState {
 
 	swerve := (leftDown && dirX == 1) || (rightDown && dirX == -1)
 	if swerve {
-		dino.Vel.X *= 0.7
+		dino.Vel.X *= tuning.Current.SwerveDamping
 	}
 
 	if dino.jumps >= 2 {
-		dino.Rotation += dirX * float64(dino.jumps) / 10
+		dino.Rotation += dirX * float64(dino.jumps) * tuning.Current.SpinRate
 	}
 
 	if !dino.Hit.Some(0b1100) {
 		dino.Pos.X += dino.Vel.X
 	}
 
-	if inpututil.IsKeyJustPressed(ebiten.KeySpace) && dino.jumps < dino.maxJumps {
-		return dino.transition(DinoStateJump)
+	if dino.Input.IsJustPressed(input.Jump) {
+		if dino.coyoteTicks > 0 {
+			dino.jumps = 0
+		}
+		if dino.jumps < tuning.Current.MaxJumps {
+			return dino.transition(DinoStateJump)
+		}
+		dino.jumpBuffer = tuning.Current.JumpBufferTicks
+	} else if dino.jumpBuffer > 0 {
+		dino.jumpBuffer--
+	}
+	if dino.coyoteTicks > 0 {
+		dino.coyoteTicks--
+	}
+
+	if dino.canClimb() {
+		return dino.transition(DinoStateClimb)
+	}
+	if ledge, ok := dino.findLedge(); ok {
+		dino.ledge = ledge
+		return dino.transition(DinoStateLedgeHang)
+	}
+	if dir := dino.wallContact(); dir != 0 {
+		dino.wallDir = dir
+		return dino.transition(DinoStateWallSlide)
+	}
+
+	if dino.Hit.Some(0b0001) && dino.Ground&level.FlagBouncy != 0 {
+		dino.Vel.Y = -math.Max(dino.Vel.Y, tuning.Current.BouncyTileSpeed)
+		dino.Hit &^= 0b0001
+		dino.jumps = 0
+		return 0
 	}
 
 	if dino.Hit.Some(0b0001) {
 		dino.Vel.Y = 0
 		dino.jumps = 0
 		dino.Rotation = 0
-		if math.Abs(dino.Vel.X) > 4.5 {
+		if dino.jumpBuffer > 0 {
+			return dino.transition(DinoStateJump)
+		}
+		if math.Abs(dino.Vel.X) > tuning.Current.RunSpeed {
 			return dino.transition(DinoStateRun)
 		} else {
 			return dino.transition(DinoStateWalk)
@@ -713,3 +1058,122 @@ func (dino *Sprite) updateFall() DinoState {
 
 	return 0
 }
+
+func (dino *Sprite) updateWallSlide() DinoState {
+	if dino.Hit.Some(0b0001) {
+		dino.Vel.Y = 0
+		dino.jumps = 0
+		return dino.transition(DinoStateIdle)
+	}
+	if dino.Input.IsJustPressed(input.Jump) {
+		return dino.transition(DinoStateWallJump)
+	}
+	if dino.wallContact() != dino.wallDir {
+		return dino.transition(DinoStateFall)
+	}
+
+	dino.Vel.Y = math.Min(dino.Vel.Y, tuning.Current.WallSlideSpeed)
+	// Keep pushing against the wall so that it's still hit.
+	dino.Pos.X += dino.wallDir * tuning.Current.AirNudge
+
+	return 0
+}
+
+func (dino *Sprite) updateWallJump() DinoState {
+	dino.Pos.X += dino.Vel.X
+
+	if dino.Hit.Some(0b0010) {
+		dino.Vel.Y *= -tuning.Current.CeilingRebound
+		return dino.transition(DinoStateFall)
+	}
+	if dino.Vel.Y >= 0 || dino.Hit.Some(wallBit(-dino.wallDir)) {
+		return dino.transition(DinoStateFall)
+	}
+
+	return 0
+}
+
+func (dino *Sprite) updateClimb() DinoState {
+	if dino.Input.IsJustPressed(input.Jump) {
+		return dino.transition(DinoStateJump)
+	}
+
+	move := dino.climbMove()
+	if move.Y > 0 && dino.Hit.Some(0b0001) {
+		dino.Actions.Add(dino.ApplyGravity)
+		return dino.transition(DinoStateIdle)
+	}
+
+	next := dino.Rect
+	next.SetMidXY(dino.Pos.X+move.X, dino.Pos.Y+move.Y)
+	if dino.Level.FlagsIn(&next)&level.FlagClimbable == 0 {
+		if move.Y < 0 {
+			dino.climbOnTop(&next)
+			dino.Actions.Add(dino.ApplyGravity)
+			return dino.transition(DinoStateIdle)
+		}
+		return dino.transition(DinoStateFall)
+	}
+
+	dino.dropThrough = true
+	dino.Pos.Add(&move)
+	return 0
+}
+
+func (dino *Sprite) updateLedgeHang() DinoState {
+	if dino.Input.IsPressed(input.Up) || dino.Input.IsJustPressed(input.Jump) {
+		return dino.transition(DinoStateLedgeClimb)
+	}
+	if dino.Input.IsPressed(input.Down) {
+		return dino.transition(DinoStateFall)
+	}
+	return 0
+}
+
+func (dino *Sprite) updateLedgeClimb() DinoState {
+	w, h := dino.Rect.Width(), dino.Rect.Height()
+	if dino.Pos.Y+h/2 > dino.ledge.Corner.Y {
+		// Climb up along the wall first, then onto the ledge.
+		dino.Pos.Y = math.Max(dino.Pos.Y-tuning.Current.LedgeClimbSpeed, dino.ledge.Corner.Y-h/2)
+	} else if (dino.Pos.X-dino.ledge.Corner.X)*dino.ledge.Dir < w/2 {
+		dino.Pos.X += dino.ledge.Dir * tuning.Current.LedgeClimbSpeed
+	} else {
+		dino.Actions.Add(dino.ApplyGravity)
+		return dino.transition(DinoStateIdle)
+	}
+	return 0
+}
+
+// updateDead respawns the dino at the last checkpoint
+// after it has been dead for a while.
+func (dino *Sprite) updateDead() DinoState {
+	dino.deadTicks++
+	if dino.deadTicks > tuning.Current.DeadTicks {
+		dino.respawn()
+		dino.Actions.Add(dino.CollideWithTile)
+		dino.jumps = 0
+		return dino.transition(DinoStateIdle)
+	}
+	return 0
+}
+
+// respawn moves the dino to the last checkpoint, and undoes
+// the jump charge if the dino died before it ended.
+func (dino *Sprite) respawn() {
+	if dino.jumpChargeState != 0 && dino.jumpChargeState != JumpChargeStateEnd {
+		dino.DrawSize = dino.jumpChargeData.size
+	}
+	dino.jumpCharge = 0
+	dino.jumpChargeState = 0
+	dino.Flip &^= 0b01
+	dino.Rotation = 0
+
+	dino.Pos = dino.Life.Checkpoint
+	dino.Vel = vector.Zero
+	dino.Hit = 0
+	dino.Ground = 0
+	dino.platform = nil
+	dino.dropThrough = false
+	dino.lastRect = dino.Rect
+	dino.lastRect.SetMidXY(dino.Pos.XY())
+}
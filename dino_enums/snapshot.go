@@ -0,0 +1,89 @@
+package dino_enums
+
+import (
+	"github.com/nvlled/dinojump/bitf"
+	"github.com/nvlled/dinojump/snapshot"
+)
+
+// resumableStates are the states that are restored as they were saved.
+// The jump charge is restored as a fall.
+var resumableStates = []string{
+	"idle", "walk", "run", "brake", "jump", "fall", "bounce", "fly",
+	"wall slide", "wall jump", "ledge hang", "ledge climb", "climb", "dead",
+}
+
+func (dino *Sprite) Save() snapshot.Dino {
+	return snapshot.Dino{
+		State:    dino.state.String(),
+		Pos:      dino.Pos,
+		Vel:      dino.Vel,
+		Flip:     dino.Flip,
+		Rotation: dino.Rotation,
+		TileID:   dino.CurrentTileID,
+
+		Gravity:   dino.Actions.Has(dino.ApplyGravity),
+		Collision: dino.Actions.Has(dino.CollideWithTile),
+
+		Hit:         byte(dino.Hit),
+		Ground:      dino.Ground,
+		DropThrough: dino.dropThrough,
+		Platform:    dino.Level.PlatformIndex(dino.platform),
+		LastRect:    dino.lastRect,
+
+		Jumps:      dino.jumps,
+		Turns:      dino.turns,
+		JumpCharge: dino.jumpCharge,
+		PreJump:    dino.preJump,
+		DeadTicks:  dino.deadTicks,
+		Coyote:     dino.coyoteTicks,
+		JumpBuffer: dino.jumpBuffer,
+		WallDir:    dino.wallDir,
+		Ledge:      dino.ledge,
+
+		Checkpoint: dino.Life.Checkpoint,
+		Deaths:     dino.Life.Deaths,
+	}
+}
+
+// Restore sets the state of a newly created dino to the saved one.
+func (dino *Sprite) Restore(snap *snapshot.Dino) {
+	name := snapshot.Resumable(snap.State, resumableStates...)
+	for state, stateName := range dinoStateNames {
+		if stateName == name {
+			// The side effects of entering the state are overwritten below.
+			dino.transition(state)
+			break
+		}
+	}
+
+	dino.Pos = snap.Pos
+	dino.Vel = snap.Vel
+	dino.Flip = snap.Flip
+	dino.Rotation = snap.Rotation
+	dino.CurrentTileID = snap.TileID
+
+	dino.Hit = bitf.T(snap.Hit)
+	dino.Ground = snap.Ground
+	dino.dropThrough = snap.DropThrough
+	dino.platform = dino.Level.PlatformAt(snap.Platform)
+	dino.lastRect = snap.LastRect
+	dino.Rect = snap.LastRect
+	dino.endPos = snap.Pos
+
+	dino.jumps = snap.Jumps
+	dino.turns = snap.Turns
+	dino.jumpCharge = snap.JumpCharge
+	dino.preJump = snap.PreJump
+	dino.deadTicks = snap.DeadTicks
+	dino.coyoteTicks = snap.Coyote
+	dino.jumpBuffer = snap.JumpBuffer
+	dino.wallDir = snap.WallDir
+	dino.ledge = snap.Ledge
+
+	dino.Life.Restore(snap.Checkpoint, snap.Deaths)
+
+	if dino.StateName() == snap.State {
+		dino.Actions.Set(dino.ApplyGravity, snap.Gravity)
+		dino.Actions.Set(dino.CollideWithTile, snap.Collision)
+	}
+}
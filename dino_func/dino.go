@@ -5,21 +5,21 @@ import (
 	"math"
 	"math/rand"
 
-	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/nvlled/dinojump/assets"
 	"github.com/nvlled/dinojump/bitf"
 	"github.com/nvlled/dinojump/common"
+	"github.com/nvlled/dinojump/input"
 	"github.com/nvlled/dinojump/level"
+	"github.com/nvlled/dinojump/life"
 	"github.com/nvlled/dinojump/numsign"
+	"github.com/nvlled/dinojump/rect"
 	"github.com/nvlled/dinojump/seqiter"
 	"github.com/nvlled/dinojump/sprite"
+	"github.com/nvlled/dinojump/tuning"
 	"github.com/nvlled/dinojump/vector"
 )
 
-var dinoMaxSpeed float64 = 20
-
 type DinoAnimation int
 
 type UpdateFn = func() func()
@@ -44,6 +44,9 @@ const (
 	AnimationFall
 	AnimationBounce
 	AnimationOuchie
+	AnimationWallSlide
+	AnimationLedgeClimb
+	AnimationClimb
 )
 
 type Sprite struct {
@@ -51,7 +54,31 @@ type Sprite struct {
 
 	Hit bitf.T
 
+	// Ground are the flags of the tile the dino stands on,
+	// Touch are the flags of all the tiles it touches.
+	Ground uint16
+	Touch  uint16
+
+	dropThrough bool
+
+	// platform is the moving platform the dino stands on.
+	platform *level.Platform
+
+	// lastRect is where the collision rect was resolved in the previous
+	// tick, and endPos is where the dino was at the end of the previous tick.
+	// The dino is swept from lastRect unless it was moved from endPos
+	// outside of Update.
+	lastRect   rect.T
+	endPos     vector.T
+	teleported bool
+
 	Level *level.T
+	Input input.Source
+	Life  life.T
+
+	state string
+
+	rng *rand.Rand
 
 	updateInit       bool
 	updateController func()
@@ -66,8 +93,21 @@ type Sprite struct {
 	jumps   int
 	preJump bool
 
-	maxJumps    int
-	maxFlySpeed float64
+	// coyoteTicks is how long the dino can still jump from
+	// the ground after walking off a ledge, and jumpBuffer is
+	// how long a jump that was pressed in the air is kept
+	// until the dino lands.
+	coyoteTicks int
+	jumpBuffer  int
+
+	// wallDir is the side of the wall the dino slides on
+	// or jumps off, -1 for the left and 1 for the right.
+	wallDir float64
+
+	// ledge is the ledge the dino hangs from or climbs onto.
+	ledge level.Ledge
+
+	deadTicks int
 
 	jumpCharge      int
 	jumpChargeState func()
@@ -83,12 +123,11 @@ func New(level *level.T) *Sprite {
 	dino := &Sprite{
 		T:     *sprite.New(img, 24, 1),
 		Level: level,
+		Input: input.Ebiten,
+		rng:   rand.New(rand.NewSource(1)),
 
 		turns: 0,
 		jumps: 0,
-
-		maxJumps:    3,
-		maxFlySpeed: 20,
 	}
 	dino.T.CollisionScale.X = 0.7
 	dino.T.CollisionScale.Y = 0.7
@@ -101,12 +140,41 @@ func New(level *level.T) *Sprite {
 }
 
 func (dino *Sprite) Update() {
+	dino.Touch = 0
+	dino.teleported = dino.Pos != dino.endPos
 	dino.T.Update()
+	if dino.state != "dead" {
+		dino.Life.Update(dino.Level, dino.Pos, &dino.Rect)
+		if dino.Touch&level.FlagHazard != 0 || life.OutOfWorld(dino.Level, &dino.Rect) {
+			dino.transition(dino.updateDead)
+		}
+	}
 	if dino.updateController != nil {
 		dino.updateController()
 	}
 	dino.updateAnimation()
+	dino.endPos = dino.Pos
+}
+
+func (dino *Sprite) SetLevel(level *level.T) {
+	dino.Level = level
+}
+
+func (dino *Sprite) SetInput(source input.Source) {
+	dino.Input = source
+}
+
+func (dino *Sprite) GetLife() *life.T {
+	return &dino.Life
+}
 
+func (dino *Sprite) StateName() string {
+	return dino.state
+}
+
+func (dino *Sprite) setState(name string) {
+	println(name)
+	dino.state = name
 }
 
 func (dino *Sprite) updateAnimation() {
@@ -117,7 +185,7 @@ func (dino *Sprite) updateAnimation() {
 	dino.CurrentTileID = dino.animationFrames.Next()
 
 	if dino.animation == AnimationRun {
-		if dino.Vel.X >= float64(dinoMaxSpeed) {
+		if dino.Vel.X >= tuning.Current.MaxSpeed {
 			dino.animationDelay = 1
 		} else {
 			dino.animationDelay = 3
@@ -143,6 +211,15 @@ func (dino *Sprite) SetAnimation(animation DinoAnimation) {
 	case AnimationRun:
 		dino.animationFrames = seqiter.CreateSeqIterator(common.RangeSlice(18, 23)...)
 		dino.animationDelay = 3
+	case AnimationWallSlide:
+		dino.animationFrames = seqiter.CreateSeqIterator(13, 15)
+		dino.animationDelay = 8
+	case AnimationLedgeClimb:
+		dino.animationFrames = seqiter.CreateSeqIterator(10, 11, 12)
+		dino.animationDelay = 4
+	case AnimationClimb:
+		dino.animationFrames = seqiter.CreateSeqIterator(11, 12)
+		dino.animationDelay = 8
 	case AnimationFly:
 		dino.animationFrames = seqiter.CreateSeqIterator(17, 18)
 		dino.animationDelay = 10
@@ -160,30 +237,143 @@ func (dino *Sprite) RestrictInWorld(common.Void) {
 }
 
 func (dino *Sprite) CollideWithTile(common.Void) {
-	level := dino.Level
-	colRect := dino.GetCollisionRect()
-	byte, l, r, t, b := level.GetTileIntersections(&colRect)
-	hit := bitf.T(byte)
-	dino.Hit = hit
+	target := dino.GetCollisionRect()
+	target.SetMidXY(dino.Pos.XY())
+
+	start := target
+	if dino.teleported {
+		start.SetMidXY(dino.Rect.MidXY())
+	} else {
+		start.SetMidXY(dino.lastRect.MidXY())
+	}
+
+	vel := vector.Sub(&target.Min, &start.Min)
+	if dino.platform != nil && !dino.teleported {
+		// Ride along with the platform it's standing on.
+		vel.Add(&dino.platform.Delta)
+	}
+
+	opts := level.SweepOptions{DropThrough: dino.dropThrough}
+	if dino.Hit.Some(0b0001) && vel.Y >= 0 {
+		// Stay on the ground when walking up and down slopes.
+		opts.StepHeight = target.Height()/2 + 1
+		opts.SnapDistance = math.Abs(vel.X) + level.ContactDistance
+	}
+	res := dino.Level.Sweep(&start, vel, opts)
+
+	dino.Hit = bitf.T(res.Hit)
+	dino.Ground = res.Ground
+	dino.platform = res.Platform
+	dino.Touch |= res.Touch
+	if res.Touch&level.FlagOneWay == 0 {
+		dino.dropThrough = false
+	}
+
+	dino.Rect = res.Rect
+	dino.Pos.Set(res.Rect.MidXY())
+	dino.lastRect = res.Rect
+}
+
+// dropDown makes the dino fall through the one-way tile it's
+// standing on when down and space are pressed.
+func (dino *Sprite) dropDown() bool {
+	if dino.Ground&level.FlagOneWay == 0 ||
+		!dino.Input.IsPressed(input.Down) ||
+		!dino.Input.IsPressed(input.Jump) {
+		return false
+	}
+	dino.dropThrough = true
+	dino.Hit &^= 0b0001
+	return true
+}
+
+// canClimb returns true if up is pressed while the dino is in front of
+// a climbable tile, or if down is pressed while it stands on one.
+func (dino *Sprite) canClimb() bool {
+	r := dino.Rect
+	if dino.Input.IsPressed(input.Up) {
+		return dino.Level.FlagsIn(&r)&level.FlagClimbable != 0
+	}
+	if dino.Input.IsPressed(input.Down) && !dino.Input.IsPressed(input.Jump) && dino.Hit.Some(0b0001) {
+		below := rect.Create(r.Left(), r.Bottom(), r.Width(), level.ContactDistance)
+		return dino.Level.FlagsIn(&below)&level.FlagClimbable != 0
+	}
+	return false
+}
 
-	if hit.Some(0b1000) {
-		dino.SetLeft(l - 1)
-	} else if hit.Some(0b0100) {
-		dino.SetRight(r)
+// climbMove returns how far the dino climbs in the pressed directions.
+func (dino *Sprite) climbMove() vector.T {
+	var move vector.T
+	speed := tuning.Current.ClimbSpeed
+	if dino.Input.IsPressed(input.Up) {
+		move.Y = -speed
+	} else if dino.Input.IsPressed(input.Down) {
+		move.Y = speed
 	}
+	if dino.Input.IsPressed(input.Left) {
+		dino.Flip = 0b10
+		move.X = -speed
+	} else if dino.Input.IsPressed(input.Right) {
+		dino.Flip = 0b00
+		move.X = speed
+	}
+	return move
+}
 
-	if hit.Some(0b0010) {
-		dino.SetTop(t - 1)
-	} else if hit.Some(0b0001) {
-		dino.SetBottom(b + 1)
+// climbOnTop moves the dino up onto the top of the climbable tiles,
+// given the rect where it's out of them.
+func (dino *Sprite) climbOnTop(next *rect.T) {
+	size := float64(dino.Level.RenderTileSize)
+	dino.Pos.Y = math.Ceil(next.Bottom()/size)*size - next.Height()/2
+	dino.dropThrough = false
+}
+
+// findLedge returns the ledge that the dino grabs when it falls
+// past it while pushing toward it.
+func (dino *Sprite) findLedge() (level.Ledge, bool) {
+	if dino.Vel.Y <= 0 || dino.Input.IsPressed(input.Down) {
+		return level.Ledge{}, false
+	}
+	dir := 0.0
+	if dino.Input.IsPressed(input.Left) {
+		dir = -1
+	} else if dino.Input.IsPressed(input.Right) {
+		dir = 1
+	} else {
+		return level.Ledge{}, false
 	}
+	return dino.Level.FindLedge(&dino.Rect, dir, dino.Vel.Y+tuning.Current.LedgeGrabReach)
+}
+
+// wallContact returns the side of the wall that the dino is falling
+// and pushing against, -1 for the left and 1 for the right, or 0 if
+// there's none.
+func (dino *Sprite) wallContact() float64 {
+	if dino.Hit.Some(0b0001) || dino.Vel.Y <= 0 {
+		return 0
+	}
+	if dino.Hit.Some(wallBit(-1)) && dino.Input.IsPressed(input.Left) {
+		return -1
+	}
+	if dino.Hit.Some(wallBit(1)) && dino.Input.IsPressed(input.Right) {
+		return 1
+	}
+	return 0
+}
+
+// wallBit is the Hit bit of the wall on the side of dir.
+func wallBit(dir float64) byte {
+	if dir < 0 {
+		return 0b1000
+	}
+	return 0b0100
 }
 
 func (dino *Sprite) ApplyGravity(common.Void) {
 	if dino.Hit.Some(0b0001) {
 		dino.Vel.Y = 0
 	} else {
-		dino.Vel.Y += 0.5
+		dino.Vel.Y += tuning.Current.Gravity
 
 	}
 	dino.Pos.Y += dino.Vel.Y
@@ -192,37 +382,55 @@ func (dino *Sprite) ApplyGravity(common.Void) {
 func (dino *Sprite) transition(updateFn func()) {
 	dino.updateInit = true
 	dino.updateController = updateFn
+	dino.coyoteTicks = 0
+	dino.jumpBuffer = 0
+}
+
+// walkOff makes the dino fall after walking off a ledge,
+// which uses up its jump from the ground after a few ticks.
+func (dino *Sprite) walkOff() {
+	dino.transition(dino.updateFall)
+	dino.jumps = 1
+	dino.coyoteTicks = tuning.Current.CoyoteTicks
 }
 
 func (dino *Sprite) updateIdle() {
 	if dino.updateInit {
-		println("idle")
+		dino.setState("idle")
 		dino.SetAnimation(AnimationIdle)
 		dino.updateInit = false
 		return
 	}
 
 	walk := false
-	if ebiten.IsKeyPressed(ebiten.KeyLeft) {
+	if dino.Input.IsPressed(input.Left) {
 		dino.Flip = 0b10
 		numsign.Set(&dino.Vel.X, -1)
 		walk = true
-	} else if ebiten.IsKeyPressed(ebiten.KeyRight) {
+	} else if dino.Input.IsPressed(input.Right) {
 		dino.Flip = 0b00
 		numsign.Set(&dino.Vel.X, 1)
 		walk = true
 	}
 
 	if !dino.Hit.Some(0b0001) {
+		dino.walkOff()
+		return
+	}
+	if dino.dropDown() {
 		dino.transition(dino.updateFall)
 		return
 	}
+	if dino.canClimb() {
+		dino.transition(dino.updateClimb)
+		return
+	}
 
 	if walk {
 		dino.transition(dino.updateWalk)
 		return
 	}
-	if ebiten.IsKeyPressed(ebiten.KeySpace) {
+	if dino.Input.IsPressed(input.Jump) {
 		dino.transition(dino.updateJump)
 		return
 	}
@@ -231,42 +439,53 @@ func (dino *Sprite) updateIdle() {
 
 func (dino *Sprite) updateWalk() {
 	if dino.updateInit {
-		println("walk")
-		dino.Vel.X = 0.5
+		dino.setState("walk")
+		dino.Vel.X = tuning.Current.WalkStartSpeed
 		dino.SetAnimation(AnimationWalk)
 		dino.updateInit = false
 		return
 	}
 
 	oldDir := numsign.Get(dino.Vel.X)
-	if ebiten.IsKeyPressed(ebiten.KeyLeft) {
+	if dino.Input.IsPressed(input.Left) {
 		dino.Flip = 0b10
 		numsign.Set(&dino.Vel.X, -1)
-	} else if ebiten.IsKeyPressed(ebiten.KeyRight) {
+	} else if dino.Input.IsPressed(input.Right) {
 		dino.Flip = 0b00
 		numsign.Set(&dino.Vel.X, 1)
+	} else if dino.Ground&level.FlagSlippery != 0 && math.Abs(dino.Vel.X) > tuning.Current.BrakeStopSpeed {
+		dino.transition(dino.updateBrake)
+		return
 	} else {
 		dino.transition(dino.updateIdle)
 		return
 	}
 
 	if !dino.Hit.Some(0b0001) {
+		dino.walkOff()
+		return
+	}
+	if dino.dropDown() {
 		dino.transition(dino.updateFall)
 		return
 	}
+	if dino.canClimb() {
+		dino.transition(dino.updateClimb)
+		return
+	}
 
 	if oldDir != numsign.Get(dino.Vel.X) {
-		dino.Vel.X *= 0.5
+		dino.Vel.X *= tuning.Current.TurnDamping
 	}
 
 	dino.Pos.X += dino.Vel.X
-	dino.Vel.X += 0.1 * numsign.Get(dino.Vel.X)
+	dino.Vel.X += tuning.Current.WalkAccel * numsign.Get(dino.Vel.X)
 
-	if ebiten.IsKeyPressed(ebiten.KeySpace) {
+	if dino.Input.IsPressed(input.Jump) {
 		dino.transition(dino.updateJump)
 		return
 	}
-	if math.Abs(dino.Vel.X) > 4.5 {
+	if math.Abs(dino.Vel.X) > tuning.Current.RunSpeed {
 		dino.transition(dino.updateRun)
 		return
 	}
@@ -274,58 +493,65 @@ func (dino *Sprite) updateWalk() {
 
 func (dino *Sprite) updateBrake() {
 	if dino.updateInit {
-		println("brake")
+		dino.setState("brake")
 		dino.SetAnimation(AnimationWalk)
 		dino.updateInit = false
 		return
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyLeft) {
+	if dino.Input.IsPressed(input.Left) {
 		dino.Flip = 0b10
-	} else if ebiten.IsKeyPressed(ebiten.KeyRight) {
+	} else if dino.Input.IsPressed(input.Right) {
 		dino.Flip = 0b00
 	}
-	if ebiten.IsKeyPressed(ebiten.KeySpace) {
+	if dino.Input.IsPressed(input.Jump) {
 		dino.transition(dino.updateJump)
 		return
 	}
-	if dino.Hit.Some(0b1100) && math.Abs(dino.Vel.X) >= float64(dinoMaxSpeed)*0.55 {
+	if dino.Hit.Some(0b1100) && math.Abs(dino.Vel.X) >= tuning.Current.BounceSpeed {
 		dino.transition(dino.updateBounce)
 		return
 	}
-	if math.Abs(dino.Vel.X) <= 1 {
+	if math.Abs(dino.Vel.X) <= tuning.Current.BrakeStopSpeed {
 		dino.transition(dino.updateIdle)
 		return
 	}
 	dino.Pos.X += dino.Vel.X
 
 	dirX := numsign.Get(dino.Vel.X)
-	if (ebiten.IsKeyPressed(ebiten.KeyLeft) && dirX > 0) ||
-		(ebiten.IsKeyPressed(ebiten.KeyRight) && dirX < 0) {
-		dino.Vel.X *= 0.90
+	slippery := dino.Ground&level.FlagSlippery != 0
+	if (dino.Input.IsPressed(input.Left) && dirX > 0) ||
+		(dino.Input.IsPressed(input.Right) && dirX < 0) {
+		if slippery {
+			dino.Vel.X *= tuning.Current.SlipperyCounterDamping
+		} else {
+			dino.Vel.X *= tuning.Current.BrakeCounterDamping
+		}
+	} else if slippery {
+		dino.Vel.X *= tuning.Current.SlipperyDamping
 	} else {
-		dino.Vel.X *= 0.97
+		dino.Vel.X *= tuning.Current.BrakeDamping
 	}
 }
 
 func (dino *Sprite) updateBounce() {
 	if dino.updateInit {
-		println("bounce")
+		dino.setState("bounce")
 		dino.SetAnimation(AnimationOuchie)
-		dino.Vel.X *= -0.8
-		dino.Vel.Y = -4.5
+		dino.Vel.X *= -tuning.Current.BounceRebound
+		dino.Vel.Y = -tuning.Current.BounceJumpSpeed
 		dino.updateInit = false
 		return
 	}
 
 	dino.Pos.X += dino.Vel.X
 	dino.Pos.Y += dino.Vel.Y
-	dino.Vel.X *= 0.9
+	dino.Vel.X *= tuning.Current.BounceDamping
 
 	if dino.Vel.Y < 0 {
-		dino.Vel.Y += 0.25
+		dino.Vel.Y += tuning.Current.Gravity
 	}
 
-	if math.Abs(dino.Vel.X) < 1 {
+	if math.Abs(dino.Vel.X) < tuning.Current.BounceStopSpeed {
 		dino.Vel.Y = 0
 		dino.Vel.X = 0
 		dino.transition(dino.updateIdle)
@@ -335,25 +561,33 @@ func (dino *Sprite) updateBounce() {
 
 func (dino *Sprite) updateRun() {
 	if dino.updateInit {
-		println("run")
+		dino.setState("run")
 		dino.SetAnimation(AnimationRun)
 		dino.updateInit = false
 		return
 	}
 
-	leftDown := ebiten.IsKeyPressed(ebiten.KeyLeft)
-	rightDown := ebiten.IsKeyPressed(ebiten.KeyRight)
+	leftDown := dino.Input.IsPressed(input.Left)
+	rightDown := dino.Input.IsPressed(input.Right)
 	noDown := !leftDown && !rightDown
 	dirX := numsign.Get(dino.Vel.X)
 	brake := noDown || (leftDown && dirX == 1) || (rightDown && dirX == -1)
 
-	if ebiten.IsKeyPressed(ebiten.KeySpace) {
+	if dino.dropDown() {
+		dino.transition(dino.updateFall)
+		return
+	}
+	if dino.canClimb() {
+		dino.transition(dino.updateClimb)
+		return
+	}
+	if dino.Input.IsPressed(input.Jump) {
 		dino.transition(dino.updateJump)
 		return
 	}
 
 	if !dino.Hit.Some(0b0001) {
-		dino.transition(dino.updateFall)
+		dino.walkOff()
 		return
 	}
 
@@ -365,26 +599,26 @@ func (dino *Sprite) updateRun() {
 	if leftDown {
 		dino.Flip = 0b10
 		numsign.Set(&dino.Vel.X, -1)
-	} else if ebiten.IsKeyPressed(ebiten.KeyRight) {
+	} else if dino.Input.IsPressed(input.Right) {
 		dino.Flip = 0b00
 		numsign.Set(&dino.Vel.X, 1)
 	}
 
-	if dino.Hit.Some(0b1100) && math.Abs(dino.Vel.X) >= float64(dinoMaxSpeed)*0.55 {
+	if dino.Hit.Some(0b1100) && math.Abs(dino.Vel.X) >= tuning.Current.BounceSpeed {
 		dino.transition(dino.updateBounce)
 		return
 	}
 
 	dino.Pos.X += dino.Vel.X
 
-	if dino.Vel.X < float64(dinoMaxSpeed) && !dino.Hit.Some(0b1100) {
-		dino.Vel.X += 0.2 * numsign.Get(dino.Vel.X)
+	if dino.Vel.X < tuning.Current.MaxSpeed && !dino.Hit.Some(0b1100) {
+		dino.Vel.X += tuning.Current.RunAccel * numsign.Get(dino.Vel.X)
 	}
 }
 
 func (dino *Sprite) updateJump() {
 	if dino.updateInit {
-		println("jump")
+		dino.setState("jump")
 		dino.jumps++
 		dino.Actions.Remove(dino.ApplyGravity)
 		dino.CurrentTileID = 12
@@ -396,16 +630,16 @@ func (dino *Sprite) updateJump() {
 
 	if dino.preJump {
 		dino.preJump = false
-		dino.Vel.Y = -7.5
+		dino.Vel.Y = -tuning.Current.JumpSpeed
 		dino.jumpCharge = 0
 		return
 	}
 
-	leftDown := ebiten.IsKeyPressed(ebiten.KeyLeft)
-	rightDown := ebiten.IsKeyPressed(ebiten.KeyRight)
+	leftDown := dino.Input.IsPressed(input.Left)
+	rightDown := dino.Input.IsPressed(input.Right)
 
 	dirX := numsign.Get(dino.Vel.X)
-	if math.Abs(dino.Vel.X) < float64(dinoMaxSpeed) {
+	if math.Abs(dino.Vel.X) < tuning.Current.MaxSpeed {
 		if leftDown {
 			dino.Flip = 0b10
 			numsign.Set(&dino.Vel.X, -1)
@@ -418,44 +652,44 @@ func (dino *Sprite) updateJump() {
 	}
 	swerve := (leftDown && dirX == 1) || (rightDown && dirX == -1)
 	if swerve {
-		dino.Vel.X *= 0.7
+		dino.Vel.X *= tuning.Current.SwerveDamping
 	}
 
 	if !dino.Hit.Some(0b1100) {
 		dino.Pos.X += dino.Vel.X
 	}
-	if ebiten.IsKeyPressed(ebiten.KeySpace) {
-		dino.Vel.Y *= 0.95
+	if dino.Input.IsPressed(input.Jump) {
+		dino.Vel.Y *= tuning.Current.JumpHoldDamping
 	} else {
-		dino.Vel.Y *= 0.55
+		dino.Vel.Y *= tuning.Current.JumpReleaseDamping
 	}
 	dino.Pos.Y += dino.Vel.Y
 
 	dino.jumpCharge++
-	if dino.jumpCharge >= 40 && ebiten.IsKeyPressed(ebiten.KeyArrowUp) {
+	if dino.jumpCharge >= tuning.Current.JumpChargeTicks && dino.Input.IsPressed(input.Up) {
 		dino.transition(dino.updateJumpCharge)
 		return
 	}
 
 	if dino.Hit.Some(0b0010) {
-		dino.Vel.Y *= -0.3
+		dino.Vel.Y *= -tuning.Current.CeilingRebound
 		dino.transition(dino.updateFall)
 		return
 	}
 
-	if math.Abs(dino.Vel.Y) < 1 {
+	if math.Abs(dino.Vel.Y) < tuning.Current.JumpFallSpeed {
 		dino.transition(dino.updateFall)
 		return
 	}
 
-	if dino.jumps >= dino.maxJumps && math.Abs(dino.Vel.X) >= float64(dinoMaxSpeed) {
+	if dino.jumps >= tuning.Current.MaxJumps && math.Abs(dino.Vel.X) >= tuning.Current.MaxSpeed {
 		dino.transition(dino.updateFly)
 		return
 	} else if dino.jumps >= 2 {
-		dino.Rotation += dirX * float64(dino.jumps) / 10
+		dino.Rotation += dirX * float64(dino.jumps) * tuning.Current.SpinRate
 	}
 
-	if dino.Hit.Some(0b1100) && math.Abs(dino.Vel.X) >= float64(dinoMaxSpeed)*0.55 {
+	if dino.Hit.Some(0b1100) && math.Abs(dino.Vel.X) >= tuning.Current.BounceSpeed {
 		dino.transition(dino.updateBounce)
 		return
 	}
@@ -465,11 +699,11 @@ func (dino *Sprite) updateJump() {
 
 func (dino *Sprite) updateJumpChargeState1() {
 	data := &dino.jumpChargeData
-	if data.pressed >= 10 {
+	if data.pressed >= float64(tuning.Current.ChargeSpins) {
 		dino.jumpChargeState = dino.updateJumpChargeState2
 		return
 	}
-	if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+	if dino.Input.IsJustPressed(input.Jump) {
 		data.idle = 0
 		data.pressed++
 		data.n += 0.05
@@ -477,7 +711,7 @@ func (dino *Sprite) updateJumpChargeState1() {
 		data.idle++
 	}
 
-	if data.idle > 150 {
+	if data.idle > tuning.Current.ChargeSpinIdle {
 		data.pressed = 0
 		dino.jumpChargeState = dino.updateJumpChargeStateEnd
 		return
@@ -487,28 +721,28 @@ func (dino *Sprite) updateJumpChargeState1() {
 
 func (dino *Sprite) updateJumpChargeState2() {
 	data := &dino.jumpChargeData
-	if data.pressed >= 30 {
+	if data.pressed >= float64(tuning.Current.ChargeShakes) {
 		dino.jumpChargeState = dino.updateJumpChargeState3
 		return
 	}
-	if inpututil.IsKeyJustPressed(ebiten.KeyArrowDown) ||
-		inpututil.IsKeyJustPressed(ebiten.KeyArrowUp) ||
-		inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft) ||
-		inpututil.IsKeyJustPressed(ebiten.KeyArrowRight) {
+	if dino.Input.IsJustPressed(input.Down) ||
+		dino.Input.IsJustPressed(input.Up) ||
+		dino.Input.IsJustPressed(input.Left) ||
+		dino.Input.IsJustPressed(input.Right) {
 		data.pressed++
 		data.idle = 0
 		dino.DrawSize.Set(data.size.X*(1+float64(data.pressed)/5), data.size.X*(1+float64(data.pressed)/5))
-		dino.Rotation += -0.1 + rand.Float64()*0.2
+		dino.Rotation += -0.1 + dino.rng.Float64()*0.2
 	} else {
 		data.idle++
 	}
-	if data.idle > 200 {
+	if data.idle > tuning.Current.ChargeShakeIdle {
 		dino.jumpChargeState = dino.updateJumpChargeStateEnd
 		return
 	}
 
-	dino.Pos.X += -0.2 + rand.Float64()*0.3
-	dino.Pos.Y += -0.2 + rand.Float64()*0.3
+	dino.Pos.X += -0.2 + dino.rng.Float64()*0.3
+	dino.Pos.Y += -0.2 + dino.rng.Float64()*0.3
 }
 
 func (dino *Sprite) updateJumpChargeState3() {
@@ -531,34 +765,34 @@ func (dino *Sprite) updateJumpChargeState3() {
 
 func (dino *Sprite) updateJumpChargeState4() {
 	data := &dino.jumpChargeData
-	if ebiten.IsKeyPressed(ebiten.KeyLeft) {
+	if dino.Input.IsPressed(input.Left) {
 		data.idle = 0
 		dino.Flip |= 0b10
-	} else if ebiten.IsKeyPressed(ebiten.KeyRight) {
+	} else if dino.Input.IsPressed(input.Right) {
 		data.idle = 0
 		dino.Flip &^= 0b10
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyUp) {
+	if dino.Input.IsPressed(input.Up) {
 		data.idle = 0
 		dino.Flip &^= 0b01
-	} else if ebiten.IsKeyPressed(ebiten.KeyDown) {
+	} else if dino.Input.IsPressed(input.Down) {
 		data.idle = 0
 		dino.Flip |= 0b01
 	}
 
-	dino.Pos.X += -0.2 + rand.Float64()*0.3
-	dino.Pos.Y += -0.2 + rand.Float64()*0.3
+	dino.Pos.X += -0.2 + dino.rng.Float64()*0.3
+	dino.Pos.Y += -0.2 + dino.rng.Float64()*0.3
 
-	if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
-		if ebiten.IsKeyPressed(ebiten.KeyArrowLeft) {
-			dino.Vel.X = -70
-		} else if ebiten.IsKeyPressed(ebiten.KeyArrowRight) {
-			dino.Vel.X = 70
+	if dino.Input.IsJustPressed(input.Jump) {
+		if dino.Input.IsPressed(input.Left) {
+			dino.Vel.X = -tuning.Current.DashSpeed
+		} else if dino.Input.IsPressed(input.Right) {
+			dino.Vel.X = tuning.Current.DashSpeed
 		}
-		if ebiten.IsKeyPressed(ebiten.KeyArrowUp) {
-			dino.Vel.Y = -70
-		} else if ebiten.IsKeyPressed(ebiten.KeyArrowDown) {
-			dino.Vel.Y = 70
+		if dino.Input.IsPressed(input.Up) {
+			dino.Vel.Y = -tuning.Current.DashSpeed
+		} else if dino.Input.IsPressed(input.Down) {
+			dino.Vel.Y = tuning.Current.DashSpeed
 		}
 
 		dino.jumpChargeState = dino.updateJumpChargeState5
@@ -566,7 +800,7 @@ func (dino *Sprite) updateJumpChargeState4() {
 	}
 
 	data.idle++
-	if data.idle > 100 {
+	if data.idle > tuning.Current.DashAimIdle {
 		dino.jumpChargeState = dino.updateJumpChargeStateEnd
 		return
 	}
@@ -574,8 +808,8 @@ func (dino *Sprite) updateJumpChargeState4() {
 
 func (dino *Sprite) updateJumpChargeState5() {
 	dino.Pos.Add(&dino.Vel)
-	dino.Vel.Scale(0.99)
-	if dino.Vel.Length() < 5 || inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+	dino.Vel.Scale(tuning.Current.DashDamping)
+	if dino.Vel.Length() < tuning.Current.DashStopSpeed || dino.Input.IsJustPressed(input.Jump) {
 		dino.jumpChargeState = dino.updateJumpChargeStateEnd
 		return
 	}
@@ -583,6 +817,7 @@ func (dino *Sprite) updateJumpChargeState5() {
 
 func (dino *Sprite) updateJumpChargeStateEnd() {
 	dino.jumpCharge = 0
+	dino.jumpChargeState = nil
 	dino.DrawSize = dino.jumpChargeData.size
 	dino.Pos = dino.jumpChargeData.pos
 	dino.Vel.Scale(0)
@@ -593,7 +828,7 @@ func (dino *Sprite) updateJumpChargeStateEnd() {
 
 func (dino *Sprite) updateJumpCharge() {
 	if dino.updateInit {
-		println("jump charge")
+		dino.setState("jump charge")
 		dino.Actions.Remove(dino.ApplyGravity)
 		dino.Actions.Remove(dino.CollideWithTile)
 		dino.jumpChargeData.n = 0.5
@@ -611,37 +846,38 @@ func (dino *Sprite) updateJumpCharge() {
 
 func (dino *Sprite) updateFly() {
 	if dino.updateInit {
-		println("fly")
+		dino.setState("fly")
 		dino.SetAnimation(AnimationNone)
 		dino.Actions.Remove(dino.ApplyGravity)
 		dino.Rotation = 0
-		dino.Vel.Scale(0.80)
+		dino.Vel.Scale(tuning.Current.FlyEntryDamping)
 		dino.updateInit = false
 		return
 	}
 
-	if ebiten.IsKeyPressed(ebiten.KeyLeft) {
+	if dino.Input.IsPressed(input.Left) {
 		numsign.Set(&dino.Vel.X, -1)
 		dino.Flip = 0b10
-		dino.Vel.X--
-	} else if ebiten.IsKeyPressed(ebiten.KeyRight) {
+		dino.Vel.X -= tuning.Current.FlyAccel
+	} else if dino.Input.IsPressed(input.Right) {
 		numsign.Set(&dino.Vel.X, 1)
 		dino.Flip = 0b00
-		dino.Vel.X++
+		dino.Vel.X += tuning.Current.FlyAccel
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyUp) {
-		dino.Vel.Y += -1
-	} else if ebiten.IsKeyPressed(ebiten.KeyDown) {
-		dino.Vel.Y += 1
+	if dino.Input.IsPressed(input.Up) {
+		dino.Vel.Y -= tuning.Current.FlyAccel
+	} else if dino.Input.IsPressed(input.Down) {
+		dino.Vel.Y += tuning.Current.FlyAccel
 	}
 
-	dino.Vel.Scale(0.97)
-	dino.Vel.ClampXY(-dinoMaxSpeed, -dinoMaxSpeed, dinoMaxSpeed, dinoMaxSpeed)
+	dino.Vel.Scale(tuning.Current.FlyDamping)
+	maxSpeed := tuning.Current.FlyMaxSpeed
+	dino.Vel.ClampXY(-maxSpeed, -maxSpeed, maxSpeed, maxSpeed)
 
 	dino.Pos.X += dino.Vel.X
 	dino.Pos.Y += dino.Vel.Y
 
-	if ebiten.IsKeyPressed(ebiten.KeySpace) && ebiten.IsKeyPressed(ebiten.KeyArrowDown) {
+	if dino.Input.IsPressed(input.Jump) && dino.Input.IsPressed(input.Down) {
 		dino.Vel.Y = 0
 		dino.transition(dino.updateFall)
 		return
@@ -651,7 +887,7 @@ func (dino *Sprite) updateFly() {
 
 func (dino *Sprite) updateFall() {
 	if dino.updateInit {
-		println("fall")
+		dino.setState("fall")
 		dino.CurrentTileID = 12
 		dino.SetAnimation(AnimationNone)
 		dino.Actions.Add(dino.ApplyGravity)
@@ -659,11 +895,11 @@ func (dino *Sprite) updateFall() {
 		return
 	}
 
-	leftDown := ebiten.IsKeyPressed(ebiten.KeyLeft)
-	rightDown := ebiten.IsKeyPressed(ebiten.KeyRight)
+	leftDown := dino.Input.IsPressed(input.Left)
+	rightDown := dino.Input.IsPressed(input.Right)
 
 	dirX := numsign.Get(dino.Vel.X)
-	if math.Abs(dino.Vel.X) < 4.5 {
+	if math.Abs(dino.Vel.X) < tuning.Current.RunSpeed {
 		if leftDown {
 			dino.Flip = 0b10
 			numsign.Set(&dino.Vel.X, -1)
@@ -677,19 +913,52 @@ func (dino *Sprite) updateFall() {
 
 	swerve := (leftDown && dirX == 1) || (rightDown && dirX == -1)
 	if swerve {
-		dino.Vel.X *= 0.7
+		dino.Vel.X *= tuning.Current.SwerveDamping
 	}
 
 	if dino.jumps >= 2 {
-		dino.Rotation += dirX * float64(dino.jumps) / 10
+		dino.Rotation += dirX * float64(dino.jumps) * tuning.Current.SpinRate
 	}
 
 	if !dino.Hit.Some(0b1100) {
 		dino.Pos.X += dino.Vel.X
 	}
 
-	if inpututil.IsKeyJustPressed(ebiten.KeySpace) && dino.jumps < dino.maxJumps {
-		dino.transition(dino.updateJump)
+	if dino.Input.IsJustPressed(input.Jump) {
+		if dino.coyoteTicks > 0 {
+			dino.jumps = 0
+		}
+		if dino.jumps < tuning.Current.MaxJumps {
+			dino.transition(dino.updateJump)
+			return
+		}
+		dino.jumpBuffer = tuning.Current.JumpBufferTicks
+	} else if dino.jumpBuffer > 0 {
+		dino.jumpBuffer--
+	}
+	if dino.coyoteTicks > 0 {
+		dino.coyoteTicks--
+	}
+
+	if dino.canClimb() {
+		dino.transition(dino.updateClimb)
+		return
+	}
+	if ledge, ok := dino.findLedge(); ok {
+		dino.ledge = ledge
+		dino.transition(dino.updateLedgeHang)
+		return
+	}
+	if dir := dino.wallContact(); dir != 0 {
+		dino.wallDir = dir
+		dino.transition(dino.updateWallSlide)
+		return
+	}
+
+	if dino.Hit.Some(0b0001) && dino.Ground&level.FlagBouncy != 0 {
+		dino.Vel.Y = -math.Max(dino.Vel.Y, tuning.Current.BouncyTileSpeed)
+		dino.Hit &^= 0b0001
+		dino.jumps = 0
 		return
 	}
 
@@ -697,7 +966,11 @@ func (dino *Sprite) updateFall() {
 		dino.Vel.Y = 0
 		dino.jumps = 0
 		dino.Rotation = 0
-		if math.Abs(dino.Vel.X) > 4.5 {
+		if dino.jumpBuffer > 0 {
+			dino.transition(dino.updateJump)
+			return
+		}
+		if math.Abs(dino.Vel.X) > tuning.Current.RunSpeed {
 			dino.transition(dino.updateRun)
 			return
 		} else {
@@ -706,3 +979,200 @@ func (dino *Sprite) updateFall() {
 		}
 	}
 }
+
+func (dino *Sprite) updateWallSlide() {
+	if dino.updateInit {
+		dino.setState("wall slide")
+		dino.SetAnimation(AnimationWallSlide)
+		dino.Rotation = 0
+		dino.Vel.X = 0
+		dino.Vel.Y = math.Min(dino.Vel.Y, tuning.Current.WallSlideSpeed)
+		dino.updateInit = false
+		return
+	}
+
+	if dino.Hit.Some(0b0001) {
+		dino.Vel.Y = 0
+		dino.jumps = 0
+		dino.transition(dino.updateIdle)
+		return
+	}
+	if dino.Input.IsJustPressed(input.Jump) {
+		dino.transition(dino.updateWallJump)
+		return
+	}
+	if dino.wallContact() != dino.wallDir {
+		dino.transition(dino.updateFall)
+		return
+	}
+
+	dino.Vel.Y = math.Min(dino.Vel.Y, tuning.Current.WallSlideSpeed)
+	// Keep pushing against the wall so that it's still hit.
+	dino.Pos.X += dino.wallDir * tuning.Current.AirNudge
+}
+
+func (dino *Sprite) updateWallJump() {
+	if dino.updateInit {
+		dino.setState("wall jump")
+		dino.SetAnimation(AnimationNone)
+		dino.CurrentTileID = 10
+		dino.jumps = 1
+		dino.Vel.X = -dino.wallDir * tuning.Current.WallJumpPush
+		dino.Vel.Y = -tuning.Current.WallJumpSpeed
+		if dino.wallDir < 0 {
+			dino.Flip = 0b00
+		} else {
+			dino.Flip = 0b10
+		}
+		dino.updateInit = false
+		return
+	}
+
+	dino.Pos.X += dino.Vel.X
+
+	if dino.Hit.Some(0b0010) {
+		dino.Vel.Y *= -tuning.Current.CeilingRebound
+		dino.transition(dino.updateFall)
+		return
+	}
+	if dino.Vel.Y >= 0 || dino.Hit.Some(wallBit(-dino.wallDir)) {
+		dino.transition(dino.updateFall)
+		return
+	}
+}
+
+func (dino *Sprite) updateClimb() {
+	if dino.updateInit {
+		dino.setState("climb")
+		dino.SetAnimation(AnimationClimb)
+		dino.Actions.Remove(dino.ApplyGravity)
+		dino.Rotation = 0
+		dino.Vel = vector.Zero
+		dino.jumps = 0
+		// Climb down through the one-way tile on top of a ladder.
+		dino.dropThrough = true
+		dino.Hit &^= 0b0001
+		dino.updateInit = false
+		return
+	}
+
+	if dino.Input.IsJustPressed(input.Jump) {
+		dino.transition(dino.updateJump)
+		return
+	}
+
+	move := dino.climbMove()
+	if move.Y > 0 && dino.Hit.Some(0b0001) {
+		dino.Actions.Add(dino.ApplyGravity)
+		dino.transition(dino.updateIdle)
+		return
+	}
+
+	next := dino.Rect
+	next.SetMidXY(dino.Pos.X+move.X, dino.Pos.Y+move.Y)
+	if dino.Level.FlagsIn(&next)&level.FlagClimbable == 0 {
+		if move.Y < 0 {
+			dino.climbOnTop(&next)
+			dino.Actions.Add(dino.ApplyGravity)
+			dino.transition(dino.updateIdle)
+			return
+		}
+		dino.transition(dino.updateFall)
+		return
+	}
+
+	dino.dropThrough = true
+	dino.Pos.Add(&move)
+}
+
+func (dino *Sprite) updateLedgeHang() {
+	if dino.updateInit {
+		dino.setState("ledge hang")
+		dino.SetAnimation(AnimationNone)
+		dino.CurrentTileID = 9
+		dino.Actions.Remove(dino.ApplyGravity)
+		dino.Rotation = 0
+		dino.Vel = vector.Zero
+		dino.jumps = 0
+		dino.Pos.Y = dino.ledge.Corner.Y + dino.Rect.Height()/2
+		dino.updateInit = false
+		return
+	}
+
+	if dino.Input.IsPressed(input.Up) || dino.Input.IsJustPressed(input.Jump) {
+		dino.transition(dino.updateLedgeClimb)
+		return
+	}
+	if dino.Input.IsPressed(input.Down) {
+		dino.transition(dino.updateFall)
+		return
+	}
+}
+
+func (dino *Sprite) updateLedgeClimb() {
+	if dino.updateInit {
+		dino.setState("ledge climb")
+		dino.SetAnimation(AnimationLedgeClimb)
+		dino.updateInit = false
+		return
+	}
+
+	w, h := dino.Rect.Width(), dino.Rect.Height()
+	if dino.Pos.Y+h/2 > dino.ledge.Corner.Y {
+		// Climb up along the wall first, then onto the ledge.
+		dino.Pos.Y = math.Max(dino.Pos.Y-tuning.Current.LedgeClimbSpeed, dino.ledge.Corner.Y-h/2)
+	} else if (dino.Pos.X-dino.ledge.Corner.X)*dino.ledge.Dir < w/2 {
+		dino.Pos.X += dino.ledge.Dir * tuning.Current.LedgeClimbSpeed
+	} else {
+		dino.Actions.Add(dino.ApplyGravity)
+		dino.transition(dino.updateIdle)
+	}
+}
+
+// updateDead makes the dino pop up and fall off the screen, then
+// respawns it at the last checkpoint after it has been dead for a while.
+func (dino *Sprite) updateDead() {
+	if dino.updateInit {
+		dino.setState("dead")
+		dino.Life.Die()
+		dino.SetAnimation(AnimationOuchie)
+		dino.Actions.Add(dino.ApplyGravity)
+		dino.Actions.Remove(dino.CollideWithTile)
+		dino.Rotation = 0
+		dino.Vel.X = 0
+		dino.Vel.Y = -tuning.Current.DeadJumpSpeed
+		dino.Hit = 0
+		dino.deadTicks = 1
+		dino.updateInit = false
+		return
+	}
+
+	dino.deadTicks++
+	if dino.deadTicks > tuning.Current.DeadTicks {
+		dino.respawn()
+		dino.Actions.Add(dino.CollideWithTile)
+		dino.jumps = 0
+		dino.transition(dino.updateIdle)
+	}
+}
+
+// respawn moves the dino to the last checkpoint, and undoes
+// the jump charge if the dino died before it ended.
+func (dino *Sprite) respawn() {
+	if dino.jumpChargeState != nil {
+		dino.DrawSize = dino.jumpChargeData.size
+	}
+	dino.jumpCharge = 0
+	dino.jumpChargeState = nil
+	dino.Flip &^= 0b01
+	dino.Rotation = 0
+
+	dino.Pos = dino.Life.Checkpoint
+	dino.Vel = vector.Zero
+	dino.Hit = 0
+	dino.Ground = 0
+	dino.platform = nil
+	dino.dropThrough = false
+	dino.lastRect = dino.Rect
+	dino.lastRect.SetMidXY(dino.Pos.XY())
+}
@@ -0,0 +1,69 @@
+package dinos
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	dino "github.com/nvlled/dinojump/dino_coroutine"
+	"github.com/nvlled/dinojump/dino_enums"
+	"github.com/nvlled/dinojump/dino_func"
+	"github.com/nvlled/dinojump/input"
+	"github.com/nvlled/dinojump/level"
+	"github.com/nvlled/dinojump/life"
+	"github.com/nvlled/dinojump/snapshot"
+	"github.com/nvlled/dinojump/sprite"
+	"github.com/nvlled/dinojump/vector"
+)
+
+// Dino is the common interface of the Sprite types
+// in dino_coroutine, dino_enums and dino_func.
+type Dino interface {
+	Update()
+	Draw(canvas *ebiten.Image)
+
+	GetSprite() *sprite.T
+	SetLevel(level *level.T)
+	SetInput(source input.Source)
+	GetLife() *life.T
+
+	StateName() string
+
+	// Save returns the state of the dino, and Restore sets
+	// the state of a newly created dino to a saved one.
+	Save() snapshot.Dino
+	Restore(snap *snapshot.Dino)
+}
+
+type Impl struct {
+	Name string
+	New  func(level *level.T) Dino
+}
+
+var All = []Impl{
+	{"coroutine", func(level *level.T) Dino { return dino.New(level) }},
+	{"enums", func(level *level.T) Dino { return dino_enums.New(level) }},
+	{"func", func(level *level.T) Dino { return dino_func.New(level) }},
+}
+
+// Configure sets the dino size relative to the level tile size.
+func Configure(dino Dino, renderTileSize int) {
+	sprite := dino.GetSprite()
+	sprite.DrawSize.X = float64(renderTileSize / 2)
+	sprite.DrawSize.Y = float64(renderTileSize / 2)
+	sprite.CollisionScale = vector.Create(1.9, 1.9)
+}
+
+func Names() []string {
+	var names []string
+	for _, impl := range All {
+		names = append(names, impl.Name)
+	}
+	return names
+}
+
+func IndexOf(name string) int {
+	for i, impl := range All {
+		if impl.Name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -2,6 +2,7 @@ package ebitenx
 
 import (
 	"image/color"
+	"math"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
@@ -58,6 +59,16 @@ func TransformImageFlip(img *ebiten.Image, op *ebiten.GeoM, flags byte) {
 	op.Translate(tx, ty)
 }
 
+// TransformImageFlipDiagonal swaps the x and y axes of the image
+// if the 0b100 flag is set. Only square images keep their bounds.
+func TransformImageFlipDiagonal(img *ebiten.Image, op *ebiten.GeoM, flags byte) {
+	if flags&0b100 == 0 {
+		return
+	}
+	op.Rotate(math.Pi / 2)
+	op.Scale(-1, 1)
+}
+
 func TransformImageRotate(img *ebiten.Image, op *ebiten.GeoM, theta float64) {
 	bounds := img.Bounds().Size()
 	op.Translate(-float64(bounds.X)/2, -float64(bounds.Y)/2)
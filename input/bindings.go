@@ -0,0 +1,257 @@
+package input
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/nvlled/dinojump/assets"
+)
+
+// A bindings file lists the inputs of an action, one action per line.
+// An action that is in the file replaces all of its default inputs.
+// Keys are named as in ebiten.Key, buttons and axes as in
+// ebiten.StandardGamepadButton and ebiten.StandardGamepadAxis,
+// and axes end with the direction they are pushed to.
+//
+//	# comments start with #
+//	left: key:ArrowLeft key:A button:LeftLeft axis:LeftStickHorizontal-
+//	jump: key:Space button:RightBottom
+//	dead-zone: 0.25
+
+// Axis is a gamepad axis pushed to a direction, either -1 or 1.
+type Axis struct {
+	Axis ebiten.StandardGamepadAxis
+	Dir  float64
+}
+
+// Binding are the inputs that trigger an action.
+type Binding struct {
+	Keys    []ebiten.Key
+	Buttons []ebiten.StandardGamepadButton
+	Axes    []Axis
+}
+
+type Bindings struct {
+	Actions [len(Actions)]Binding
+
+	// DeadZone is how far an axis has to be pushed
+	// before its action is pressed, from 0 to 1.
+	DeadZone float64
+}
+
+var DefaultBindings = Bindings{
+	Actions: [...]Binding{
+		Left: {
+			Keys:    []ebiten.Key{ebiten.KeyArrowLeft},
+			Buttons: []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonLeftLeft},
+			Axes:    []Axis{{ebiten.StandardGamepadAxisLeftStickHorizontal, -1}},
+		},
+		Right: {
+			Keys:    []ebiten.Key{ebiten.KeyArrowRight},
+			Buttons: []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonLeftRight},
+			Axes:    []Axis{{ebiten.StandardGamepadAxisLeftStickHorizontal, 1}},
+		},
+		Up: {
+			Keys:    []ebiten.Key{ebiten.KeyArrowUp},
+			Buttons: []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonLeftTop},
+			Axes:    []Axis{{ebiten.StandardGamepadAxisLeftStickVertical, -1}},
+		},
+		Down: {
+			Keys:    []ebiten.Key{ebiten.KeyArrowDown},
+			Buttons: []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonLeftBottom},
+			Axes:    []Axis{{ebiten.StandardGamepadAxisLeftStickVertical, 1}},
+		},
+		Jump: {
+			Keys:    []ebiten.Key{ebiten.KeySpace},
+			Buttons: []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonRightBottom},
+		},
+	},
+	DeadZone: 0.25,
+}
+
+// CurrentBindings are the bindings used by Poll and Ebiten.
+var CurrentBindings = DefaultBindings
+
+func (bindings *Bindings) IsPressed(action Action) bool {
+	binding := &bindings.Actions[action]
+	for _, key := range binding.Keys {
+		if ebiten.IsKeyPressed(key) {
+			return true
+		}
+	}
+	for _, id := range gamepads() {
+		for _, button := range binding.Buttons {
+			if ebiten.IsStandardGamepadButtonPressed(id, button) {
+				return true
+			}
+		}
+		for _, axis := range binding.Axes {
+			if ebiten.StandardGamepadAxisValue(id, axis.Axis)*axis.Dir > bindings.DeadZone {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (bindings *Bindings) IsJustPressed(action Action) bool {
+	binding := &bindings.Actions[action]
+	for _, key := range binding.Keys {
+		if inpututil.IsKeyJustPressed(key) {
+			return true
+		}
+	}
+	for _, button := range binding.Buttons {
+		if isButtonJustPressed(button) {
+			return true
+		}
+	}
+	return false
+}
+
+func (bindings *Bindings) IsJustReleased(action Action) bool {
+	binding := &bindings.Actions[action]
+	for _, key := range binding.Keys {
+		if inpututil.IsKeyJustReleased(key) {
+			return true
+		}
+	}
+	for _, button := range binding.Buttons {
+		if isButtonJustReleased(button) {
+			return true
+		}
+	}
+	return false
+}
+
+var buttonNames = map[string]ebiten.StandardGamepadButton{
+	"rightbottom":      ebiten.StandardGamepadButtonRightBottom,
+	"rightright":       ebiten.StandardGamepadButtonRightRight,
+	"rightleft":        ebiten.StandardGamepadButtonRightLeft,
+	"righttop":         ebiten.StandardGamepadButtonRightTop,
+	"fronttopleft":     ebiten.StandardGamepadButtonFrontTopLeft,
+	"fronttopright":    ebiten.StandardGamepadButtonFrontTopRight,
+	"frontbottomleft":  ebiten.StandardGamepadButtonFrontBottomLeft,
+	"frontbottomright": ebiten.StandardGamepadButtonFrontBottomRight,
+	"centerleft":       ebiten.StandardGamepadButtonCenterLeft,
+	"centerright":      ebiten.StandardGamepadButtonCenterRight,
+	"leftstick":        ebiten.StandardGamepadButtonLeftStick,
+	"rightstick":       ebiten.StandardGamepadButtonRightStick,
+	"lefttop":          ebiten.StandardGamepadButtonLeftTop,
+	"leftbottom":       ebiten.StandardGamepadButtonLeftBottom,
+	"leftleft":         ebiten.StandardGamepadButtonLeftLeft,
+	"leftright":        ebiten.StandardGamepadButtonLeftRight,
+	"centercenter":     ebiten.StandardGamepadButtonCenterCenter,
+}
+
+var axisNames = map[string]ebiten.StandardGamepadAxis{
+	"leftstickhorizontal":  ebiten.StandardGamepadAxisLeftStickHorizontal,
+	"leftstickvertical":    ebiten.StandardGamepadAxisLeftStickVertical,
+	"rightstickhorizontal": ebiten.StandardGamepadAxisRightStickHorizontal,
+	"rightstickvertical":   ebiten.StandardGamepadAxisRightStickVertical,
+}
+
+type ParseError struct {
+	Filename string
+	Line     int
+	Msg      string
+}
+
+func (err *ParseError) Error() string {
+	return fmt.Sprintf("%v:%v: %v", err.Filename, err.Line, err.Msg)
+}
+
+// LoadBindings reads a bindings file from the disk, or the
+// embedded one if there is no such file on the disk.
+func LoadBindings(filename string) (Bindings, error) {
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ParseBindings(filename, assets.BindingsData)
+	}
+	if err != nil {
+		return DefaultBindings, err
+	}
+	return ParseBindings(filename, data)
+}
+
+func ParseBindings(filename string, data []byte) (Bindings, error) {
+	result := DefaultBindings
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		errorf := func(format string, args ...any) error {
+			return &ParseError{Filename: filename, Line: i + 1, Msg: fmt.Sprintf(format, args...)}
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return DefaultBindings, errorf("expected \"action: inputs\"")
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		if key == "dead-zone" {
+			deadZone, err := strconv.ParseFloat(value, 64)
+			if err != nil || deadZone < 0 || deadZone >= 1 {
+				return DefaultBindings, errorf("invalid dead zone %q, must be between 0 and 1", value)
+			}
+			result.DeadZone = deadZone
+			continue
+		}
+
+		action, ok := actionOf(key)
+		if !ok {
+			return DefaultBindings, errorf("unknown action %q", key)
+		}
+		var binding Binding
+		for _, input := range strings.Fields(value) {
+			kind, name, _ := strings.Cut(input, ":")
+			switch kind {
+			case "key":
+				var k ebiten.Key
+				if err := k.UnmarshalText([]byte(name)); err != nil {
+					return DefaultBindings, errorf("unknown key %q", name)
+				}
+				binding.Keys = append(binding.Keys, k)
+			case "button":
+				button, ok := buttonNames[strings.ToLower(name)]
+				if !ok {
+					return DefaultBindings, errorf("unknown gamepad button %q", name)
+				}
+				binding.Buttons = append(binding.Buttons, button)
+			case "axis":
+				dir := 1.0
+				if strings.HasSuffix(name, "-") {
+					dir = -1
+				} else if !strings.HasSuffix(name, "+") {
+					return DefaultBindings, errorf("gamepad axis %q must end with + or -", name)
+				}
+				axis, ok := axisNames[strings.ToLower(name[:len(name)-1])]
+				if !ok {
+					return DefaultBindings, errorf("unknown gamepad axis %q", name)
+				}
+				binding.Axes = append(binding.Axes, Axis{axis, dir})
+			default:
+				return DefaultBindings, errorf("expected key, button or axis instead of %q", input)
+			}
+		}
+		result.Actions[action] = binding
+	}
+	return result, nil
+}
+
+func actionOf(name string) (Action, bool) {
+	for _, action := range Actions {
+		if action.String() == name {
+			return action, true
+		}
+	}
+	return 0, false
+}
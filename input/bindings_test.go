@@ -0,0 +1,89 @@
+//go:build !nodisplay
+
+package input
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/nvlled/dinojump/assets"
+)
+
+func TestParseBindings(t *testing.T) {
+	data := `
+# a comment
+jump: key:Z key:Space button:RightRight
+left: axis:RightStickHorizontal- key:A
+up: axis:LeftStickVertical+
+dead-zone: 0.5
+`
+	bindings, err := ParseBindings("test.txt", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := DefaultBindings
+	expected.Actions[Jump] = Binding{
+		Keys:    []ebiten.Key{ebiten.KeyZ, ebiten.KeySpace},
+		Buttons: []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonRightRight},
+	}
+	expected.Actions[Left] = Binding{
+		Keys: []ebiten.Key{ebiten.KeyA},
+		Axes: []Axis{{ebiten.StandardGamepadAxisRightStickHorizontal, -1}},
+	}
+	expected.Actions[Up] = Binding{
+		Axes: []Axis{{ebiten.StandardGamepadAxisLeftStickVertical, 1}},
+	}
+	expected.DeadZone = 0.5
+	if !reflect.DeepEqual(bindings, expected) {
+		t.Errorf("expected %+v, got %+v", expected, bindings)
+	}
+
+	// The actions that aren't in the file keep their defaults.
+	if !reflect.DeepEqual(DefaultBindings.Actions[Right], bindings.Actions[Right]) {
+		t.Errorf("expected the default bindings of right, got %+v", bindings.Actions[Right])
+	}
+}
+
+func TestParseBindingsErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+		line int
+		msg  string
+	}{
+		{"unknown key", "jump: key:Space\nleft: key:Lft\n", 2, `unknown key "Lft"`},
+		{"unknown button", "jump: button:RightMiddle\n", 1, `unknown gamepad button "RightMiddle"`},
+		{"unknown axis", "up: axis:LeftStickDiagonal-\n", 1, `unknown gamepad axis "LeftStickDiagonal-"`},
+		{"axis without a direction", "up: axis:LeftStickVertical\n", 1, `gamepad axis "LeftStickVertical" must end with + or -`},
+		{"unknown input kind", "up: mouse:Left\n", 1, `expected key, button or axis instead of "mouse:Left"`},
+		{"unknown action", "# comment\nduck: key:C\n", 2, `unknown action "duck"`},
+		{"bad dead zone", "dead-zone: 1.5\n", 1, `invalid dead zone "1.5", must be between 0 and 1`},
+	}
+	for _, c := range cases {
+		bindings, err := ParseBindings("test.txt", []byte(c.data))
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("%v: expected a parse error, got %v", c.name, err)
+			continue
+		}
+		if perr.Line != c.line || perr.Msg != c.msg {
+			t.Errorf("%v: expected %q on line %v, got %v", c.name, c.msg, c.line, perr)
+		}
+		if !reflect.DeepEqual(bindings, DefaultBindings) {
+			t.Errorf("%v: expected the default bindings on errors", c.name)
+		}
+	}
+}
+
+func TestDefaultBindingsMatchAssets(t *testing.T) {
+	bindings, err := ParseBindings("bindings.txt", assets.BindingsData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(bindings, DefaultBindings) {
+		t.Errorf("expected the assets to have the default bindings, got %+v", bindings)
+	}
+}
@@ -0,0 +1,124 @@
+package input
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// Action is a named input that the dino controllers read,
+// bound to keys and gamepad buttons and axes, see Bindings.
+type Action uint8
+
+// The order of the actions is their bit in a State,
+// and so it must not change or the replays will break.
+const (
+	Left Action = iota
+	Right
+	Up
+	Down
+	Jump
+)
+
+var Actions = [...]Action{Left, Right, Up, Down, Jump}
+
+var actionNames = [...]string{"left", "right", "up", "down", "jump"}
+
+func (action Action) String() string {
+	if int(action) < len(actionNames) {
+		return actionNames[action]
+	}
+	return "unknown"
+}
+
+// Source is where the dino controllers read their input from.
+type Source interface {
+	IsPressed(action Action) bool
+	IsJustPressed(action Action) bool
+	IsJustReleased(action Action) bool
+}
+
+type ebitenSource struct{}
+
+func (ebitenSource) IsPressed(action Action) bool      { return CurrentBindings.IsPressed(action) }
+func (ebitenSource) IsJustPressed(action Action) bool  { return CurrentBindings.IsJustPressed(action) }
+func (ebitenSource) IsJustReleased(action Action) bool { return CurrentBindings.IsJustReleased(action) }
+
+// Ebiten reads the input directly from ebiten, using the CurrentBindings.
+// Gamepad axes are only reported as pressed, never as just pressed or released.
+var Ebiten Source = ebitenSource{}
+
+// State is a bitset of the pressed Actions in a single tick.
+type State uint8
+
+func StateOf(actions ...Action) State {
+	var state State
+	for _, action := range actions {
+		state |= 1 << action
+	}
+	return state
+}
+
+// Poll returns the currently pressed Actions.
+func Poll() State {
+	var state State
+	for _, action := range Actions {
+		if CurrentBindings.IsPressed(action) {
+			state |= 1 << action
+		}
+	}
+	return state
+}
+
+func (state State) Has(action Action) bool {
+	return state&(1<<action) != 0
+}
+
+// Frame is a Source that reports the Current state.
+// Just pressed actions are the ones that are not in the Prev state,
+// and just released actions are the ones that are only in the Prev state.
+type Frame struct {
+	Prev    State
+	Current State
+}
+
+func (frame *Frame) Push(state State) {
+	frame.Prev = frame.Current
+	frame.Current = state
+}
+
+func (frame *Frame) IsPressed(action Action) bool {
+	return frame.Current.Has(action)
+}
+
+func (frame *Frame) IsJustPressed(action Action) bool {
+	return frame.Current.Has(action) && !frame.Prev.Has(action)
+}
+
+func (frame *Frame) IsJustReleased(action Action) bool {
+	return !frame.Current.Has(action) && frame.Prev.Has(action)
+}
+
+var gamepadIDs []ebiten.GamepadID
+
+func gamepads() []ebiten.GamepadID {
+	gamepadIDs = ebiten.AppendGamepadIDs(gamepadIDs[:0])
+	return gamepadIDs
+}
+
+func isButtonJustPressed(button ebiten.StandardGamepadButton) bool {
+	for _, id := range gamepads() {
+		if inpututil.IsStandardGamepadButtonJustPressed(id, button) {
+			return true
+		}
+	}
+	return false
+}
+
+func isButtonJustReleased(button ebiten.StandardGamepadButton) bool {
+	for _, id := range gamepads() {
+		if inpututil.IsStandardGamepadButtonJustReleased(id, button) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package input
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nvlled/dinojump/vector"
+)
+
+var replayMagic = []byte("DJRP")
+
+const replayVersion = 2
+
+// Replay is a recording of the input states of every tick,
+// starting from a freshly spawned dino.
+type Replay struct {
+	Dino   string
+	Level  string
+	Spawn  vector.T
+	States []State
+}
+
+// Encode writes the replay to w. The states are run-length encoded,
+// since they rarely change between ticks.
+func (replay *Replay) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.Write(replayMagic)
+	bw.WriteByte(replayVersion)
+
+	writeString(bw, replay.Dino)
+	writeString(bw, replay.Level)
+	binary.Write(bw, binary.LittleEndian, replay.Spawn.X)
+	binary.Write(bw, binary.LittleEndian, replay.Spawn.Y)
+
+	writeUvarint(bw, uint64(len(replay.States)))
+	for i := 0; i < len(replay.States); {
+		state := replay.States[i]
+		n := 1
+		for i+n < len(replay.States) && replay.States[i+n] == state {
+			n++
+		}
+		bw.WriteByte(byte(state))
+		writeUvarint(bw, uint64(n))
+		i += n
+	}
+
+	return bw.Flush()
+}
+
+func DecodeReplay(r io.Reader) (*Replay, error) {
+	br := bufio.NewReader(r)
+
+	header := make([]byte, len(replayMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("replay: failed to read header: %w", err)
+	}
+	if string(header[:len(replayMagic)]) != string(replayMagic) {
+		return nil, errors.New("replay: not a replay file")
+	}
+	if version := header[len(replayMagic)]; version != replayVersion {
+		return nil, fmt.Errorf("replay: unsupported version %v", version)
+	}
+
+	replay := &Replay{}
+
+	var err error
+	if replay.Dino, err = readString(br); err != nil {
+		return nil, fmt.Errorf("replay: failed to read dino name: %w", err)
+	}
+	if replay.Level, err = readString(br); err != nil {
+		return nil, fmt.Errorf("replay: failed to read level name: %w", err)
+	}
+
+	if err := binary.Read(br, binary.LittleEndian, &replay.Spawn.X); err != nil {
+		return nil, fmt.Errorf("replay: failed to read spawn point: %w", err)
+	}
+	if err := binary.Read(br, binary.LittleEndian, &replay.Spawn.Y); err != nil {
+		return nil, fmt.Errorf("replay: failed to read spawn point: %w", err)
+	}
+
+	numStates, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, fmt.Errorf("replay: failed to read tick count: %w", err)
+	}
+	for uint64(len(replay.States)) < numStates {
+		state, err := br.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("replay: truncated at tick %v: %w", len(replay.States), err)
+		}
+		run, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, fmt.Errorf("replay: truncated at tick %v: %w", len(replay.States), err)
+		}
+		if run == 0 || uint64(len(replay.States))+run > numStates {
+			return nil, fmt.Errorf("replay: invalid run length at tick %v", len(replay.States))
+		}
+		for i := uint64(0); i < run; i++ {
+			replay.States = append(replay.States, State(state))
+		}
+	}
+
+	return replay, nil
+}
+
+func LoadReplay(filename string) (*Replay, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return DecodeReplay(file)
+}
+
+func SaveReplay(filename string, replay *Replay) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := replay.Encode(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func writeUvarint(w *bufio.Writer, x uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], x)
+	w.Write(buf[:n])
+}
+
+func writeString(w *bufio.Writer, s string) {
+	writeUvarint(w, uint64(len(s)))
+	w.WriteString(s)
+}
+
+func readString(r *bufio.Reader) (string, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+	if n > 4096 {
+		return "", errors.New("string is too long")
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,87 @@
+//go:build !nodisplay
+
+package input
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nvlled/dinojump/vector"
+)
+
+func encode(t *testing.T, replay *Replay) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := replay.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReplayRoundTrip(t *testing.T) {
+	script := NewScript().Wait(30).Hold(100, Right).Hold(10, Right, Jump).Press(Left).Wait(1000)
+	replay := &Replay{
+		Dino:   "coroutine",
+		Level:  "levels/level1.level",
+		Spawn:  vector.Create(87.5, 212.25),
+		States: script.States,
+	}
+	data := encode(t, replay)
+
+	// After the header and the tick count, five runs of a state
+	// and a length, which takes two bytes for the last one.
+	header := len(replayMagic) + 1 + 1 + len(replay.Dino) + 1 + len(replay.Level) + 16
+	if expected := header + 2 + 5*2 + 1; len(data) != expected {
+		t.Errorf("expected %v bytes, got %v", expected, len(data))
+	}
+
+	decoded, err := DecodeReplay(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, replay) {
+		t.Errorf("expected %+v, got %+v", replay, decoded)
+	}
+}
+
+func TestReplayDecodeErrors(t *testing.T) {
+	valid := encode(t, &Replay{
+		Dino:   "enums",
+		Level:  "arena.level",
+		States: NewScript().Hold(5, Jump).Wait(5).States,
+	})
+
+	badMagic := append([]byte("DJRX"), valid[4:]...)
+
+	wrongVersion := append([]byte{}, valid...)
+	wrongVersion[len(replayMagic)] = replayVersion + 1
+
+	// Two ticks are missing from the last run.
+	tooLong := append([]byte{}, valid...)
+	tooLong[len(tooLong)-1] = 7
+
+	longString := append([]byte{}, valid[:len(replayMagic)+1]...)
+	longString = append(longString, 0x88, 0x27) // 5000 as a uvarint
+	longString = append(longString, bytes.Repeat([]byte("x"), 5000)...)
+
+	cases := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"bad magic", badMagic, "not a replay file"},
+		{"wrong version", wrongVersion, "unsupported version"},
+		{"truncated run", valid[:len(valid)-2], "truncated at tick 5"},
+		{"run past the end", tooLong, "invalid run length at tick 5"},
+		{"oversized string", longString, "string is too long"},
+		{"empty", nil, "failed to read header"},
+	}
+	for _, c := range cases {
+		_, err := DecodeReplay(bytes.NewReader(c.data))
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%v: expected an error with %q, got %v", c.name, c.err, err)
+		}
+	}
+}
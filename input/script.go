@@ -0,0 +1,56 @@
+package input
+
+// Script is a Source that plays a scripted input timeline,
+// one State per tick. No actions are pressed after the end of the script.
+//
+//	script := input.NewScript().
+//		Hold(30, input.Right).
+//		Press(input.Jump).
+//		Wait(60)
+type Script struct {
+	Frame
+
+	States []State
+	Tick   int
+}
+
+func NewScript(states ...State) *Script {
+	return &Script{States: states}
+}
+
+// Hold appends a section where the actions are held down for the given ticks.
+func (script *Script) Hold(ticks int, actions ...Action) *Script {
+	state := StateOf(actions...)
+	for i := 0; i < ticks; i++ {
+		script.States = append(script.States, state)
+	}
+	return script
+}
+
+// Press appends a single tick where the actions are pressed.
+func (script *Script) Press(actions ...Action) *Script {
+	return script.Hold(1, actions...)
+}
+
+// Wait appends a section where no actions are pressed.
+func (script *Script) Wait(ticks int) *Script {
+	return script.Hold(ticks)
+}
+
+func (script *Script) Len() int {
+	return len(script.States)
+}
+
+func (script *Script) IsDone() bool {
+	return script.Tick >= len(script.States)
+}
+
+// Next advances the script by one tick.
+func (script *Script) Next() {
+	var state State
+	if script.Tick < len(script.States) {
+		state = script.States[script.Tick]
+	}
+	script.Push(state)
+	script.Tick++
+}
@@ -0,0 +1,644 @@
+package level
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nvlled/dinojump/assets"
+	"github.com/nvlled/dinojump/vector"
+)
+
+// A level file has a header followed by the tile grid,
+// separated by a line containing only "---".
+//
+//	# comments start with #
+//	atlas: lemcraft-tiles.png
+//	atlas-size: 7 8
+//	background: Cielo pixelado.png
+//	tilesize: 50
+//	tile: v 28
+//	tile: * 12 oneway
+//	tile: ~ 13 0x0
+//	tile: / 30 floor45r
+//	spawn: 4 4
+//	platform: * 2 3,1 7,1 speed=1.5
+//	object: goal 8,0
+//	object: trigger 2,1 3x1 name=door message=hello
+//	---
+//	|vvvvvvvvvv|
+//	|   ****   |
+//	|^^^^^^^^^^|
+//
+// The tile line maps a rune in the grid to a tile ID of the atlas,
+// with optional tile flags, either as a number or as names separated
+// by commas (see ParseFlags), and an optional slope (see ParseSlope).
+// Tiles without flags are solid. The spawn is the column and row
+// of the tile where the dino starts.
+//
+// The platform line adds a moving platform made of the tile of a rune,
+// with the number of columns (or "COLSxROWS"), followed by the column
+// and row of its top left on each waypoint of the path. Its options
+// are speed=N in pixels per tick (default 1), name=NAME, and loop to go
+// back to the first waypoint instead of going back and forth.
+//
+// The object line adds an object of any type (see Object) at a column and
+// row, with an optional size of "COLSxROWS" tiles, a name=NAME and other
+// key=value properties.
+//
+// More grids can follow as additional layers. A "---" line may
+// be followed by the layer name and its options:
+//
+//	--- decor depth=background parallax=0.5 collide=false
+//	--- overlay depth=foreground hidden
+//
+// All the rows of a grid have the same number of columns, but the
+// layers can be smaller than the level.
+//
+// The depth is one of background, main or foreground. The parallax
+// is either one factor for both axes or "x,y". Only the main layers
+// collide by default. The first grid is named "main" if it has no name.
+
+type ParseError struct {
+	Filename string
+	Line     int
+	Col      int
+	Msg      string
+}
+
+func (err *ParseError) Error() string {
+	return fmt.Sprintf("%v:%v:%v: %v", err.Filename, err.Line, err.Col, err.Msg)
+}
+
+// File is a parsed level file.
+type File struct {
+	Name    string
+	Options NewOptions
+	Layers  []FileLayer
+}
+
+type FileLayer struct {
+	Name     string
+	Depth    Depth
+	Hidden   bool
+	Collide  bool
+	Parallax vector.T
+	Grid     []string
+}
+
+type fileParser struct {
+	filename string
+	lineNum  int
+	line     string
+}
+
+func (p *fileParser) errorf(col int, format string, args ...any) error {
+	return &ParseError{
+		Filename: p.filename,
+		Line:     p.lineNum,
+		Col:      col,
+		Msg:      fmt.Sprintf(format, args...),
+	}
+}
+
+// column returns the 1-based column of the byte offset in the current line.
+func (p *fileParser) column(offset int) int {
+	return utf8.RuneCountInString(p.line[:offset]) + 1
+}
+
+type headerField struct {
+	value string
+	col   int
+}
+
+// fields splits the value into space separated fields,
+// keeping track of the columns of each field.
+func (p *fileParser) fields(value string, col int) []headerField {
+	var result []headerField
+	start := -1
+	for i, ch := range value + " " {
+		if ch == ' ' || ch == '\t' {
+			if start >= 0 {
+				result = append(result, headerField{
+					value: value[start:i],
+					col:   col + utf8.RuneCountInString(value[:start]),
+				})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	return result
+}
+
+func (p *fileParser) parseInt(field headerField, min, max int) (int, error) {
+	n, err := strconv.Atoi(field.value)
+	if err != nil {
+		return 0, p.errorf(field.col, "invalid number %q", field.value)
+	}
+	if n < min || n > max {
+		return 0, p.errorf(field.col, "number %v is out of range [%v, %v]", n, min, max)
+	}
+	return n, nil
+}
+
+// ParseFile parses the contents of a level file.
+// The returned error is a *ParseError for malformed input.
+func ParseFile(filename string, data []byte) (*File, error) {
+	p := &fileParser{filename: filename}
+	file := &File{
+		Name: filename,
+		Options: NewOptions{
+			TileMap:        map[rune]Tile{},
+			RenderTileSize: DefaultRenderTileSize,
+		},
+	}
+	options := &file.Options
+
+	lines := strings.Split(string(data), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+
+	seen := map[string]int{}
+	spawnLine := 0
+	var spawnCol int
+
+	gridStart := -1
+	for i, line := range lines {
+		p.lineNum = i + 1
+		p.line = line
+
+		trimmed := strings.TrimSpace(line)
+		if isLayerLine(trimmed) {
+			gridStart = i
+			break
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		sep := strings.Index(line, ":")
+		if sep < 0 {
+			return nil, p.errorf(p.column(len(line)-len(strings.TrimLeft(line, " \t"))), "expected \"key: value\" or \"---\"")
+		}
+
+		key := strings.TrimSpace(line[:sep])
+		keyCol := p.column(strings.Index(line, key))
+		value := strings.TrimSpace(line[sep+1:])
+		valueCol := p.column(sep + 1)
+		if value != "" {
+			valueCol = p.column(sep + 1 + strings.Index(line[sep+1:], value))
+		}
+
+		if key != "tile" && key != "platform" && key != "object" {
+			if prev, ok := seen[key]; ok {
+				return nil, p.errorf(keyCol, "duplicate key %q, already declared on line %v", key, prev)
+			}
+			seen[key] = p.lineNum
+		}
+
+		fields := p.fields(value, valueCol)
+
+		switch key {
+		case "atlas":
+			if value == "" {
+				return nil, p.errorf(valueCol, "missing atlas filename")
+			}
+			options.AtlasFilename = value
+
+		case "atlas-size":
+			if len(fields) != 2 {
+				return nil, p.errorf(valueCol, "expected number of columns and rows")
+			}
+			cols, err := p.parseInt(fields[0], 1, 1<<16)
+			if err != nil {
+				return nil, err
+			}
+			rows, err := p.parseInt(fields[1], 1, 1<<16)
+			if err != nil {
+				return nil, err
+			}
+			options.AtlasCols, options.AtlasRows = cols, rows
+
+		case "background":
+			if value == "" {
+				return nil, p.errorf(valueCol, "missing background filename")
+			}
+			options.BackgroundFilename = value
+
+		case "tilesize":
+			if len(fields) != 1 {
+				return nil, p.errorf(valueCol, "expected a tile size")
+			}
+			size, err := p.parseInt(fields[0], 1, 1<<16)
+			if err != nil {
+				return nil, err
+			}
+			options.RenderTileSize = size
+
+		case "tile":
+			if len(fields) < 2 || len(fields) > 4 {
+				return nil, p.errorf(valueCol, "expected a rune, a tile ID, and optional flags and slope")
+			}
+			ch, size := utf8.DecodeRuneInString(fields[0].value)
+			if size != len(fields[0].value) {
+				return nil, p.errorf(fields[0].col, "expected a single rune, got %q", fields[0].value)
+			}
+			if _, ok := options.TileMap[ch]; ok {
+				return nil, p.errorf(fields[0].col, "duplicate tile %q", ch)
+			}
+			id, err := p.parseInt(fields[1], -1, 1<<16)
+			if err != nil {
+				return nil, err
+			}
+			tile := CreateTile(id)
+			hasFlags := false
+			for _, field := range fields[2:] {
+				if slope, err := ParseSlope(field.value); err == nil {
+					tile.Slope = slope
+					continue
+				}
+				if hasFlags {
+					return nil, p.errorf(field.col, "the tile flags are already set, separate them with commas")
+				}
+				flags, err := ParseFlags(field.value)
+				if err != nil {
+					return nil, p.errorf(field.col, "%v", err)
+				}
+				tile.Flags = flags
+				hasFlags = true
+			}
+			options.TileMap[ch] = tile
+
+		case "platform":
+			platform, err := p.parsePlatform(fields, options.TileMap)
+			if err != nil {
+				return nil, err
+			}
+			if len(platform.Path) == 0 {
+				return nil, p.errorf(valueCol, "expected a rune, a size and the waypoints")
+			}
+			options.Platforms = append(options.Platforms, platform)
+
+		case "object":
+			obj, err := p.parseObject(fields)
+			if err != nil {
+				return nil, err
+			}
+			if obj.Type == "" {
+				return nil, p.errorf(valueCol, "expected a type, a column and a row")
+			}
+			options.Objects = append(options.Objects, obj)
+
+		case "spawn":
+			if len(fields) != 2 {
+				return nil, p.errorf(valueCol, "expected a column and a row")
+			}
+			col, err := p.parseInt(fields[0], 0, 1<<16)
+			if err != nil {
+				return nil, err
+			}
+			row, err := p.parseInt(fields[1], 0, 1<<16)
+			if err != nil {
+				return nil, err
+			}
+			options.Spawn = &image.Point{X: col, Y: row}
+			spawnLine, spawnCol = p.lineNum, valueCol
+
+		default:
+			return nil, p.errorf(keyCol, "unknown key %q", key)
+		}
+	}
+
+	if gridStart < 0 {
+		p.lineNum = len(lines)
+		return nil, p.errorf(1, "missing \"---\" line between the header and the grid")
+	}
+	if options.AtlasFilename == "" {
+		return nil, p.errorf(1, "missing atlas in the header")
+	}
+
+	names := map[string]int{}
+	for i := gridStart; i < len(lines); {
+		p.lineNum = i + 1
+		p.line = lines[i]
+
+		layer, err := p.parseLayerLine(len(file.Layers) == 0)
+		if err != nil {
+			return nil, err
+		}
+		if prev, ok := names[layer.Name]; ok {
+			return nil, p.errorf(1, "duplicate layer %q, already declared on line %v", layer.Name, prev)
+		}
+		names[layer.Name] = p.lineNum
+
+		i++
+		start := i
+		for i < len(lines) && !isLayerLine(strings.TrimSpace(lines[i])) {
+			i++
+		}
+		grid := lines[start:i]
+		for len(grid) > 0 && strings.TrimSpace(grid[len(grid)-1]) == "" {
+			grid = grid[:len(grid)-1]
+		}
+		if len(grid) == 0 {
+			return nil, p.errorf(1, "the grid of layer %q is empty", layer.Name)
+		}
+
+		width := utf8.RuneCountInString(grid[0])
+		for r, line := range grid {
+			p.lineNum = start + r + 1
+			p.line = line
+			if n := utf8.RuneCountInString(line); n != width {
+				col := width + 1
+				if n < width {
+					col = n + 1
+				}
+				return nil, p.errorf(col, "row has %v columns, expected %v like the first row of the grid", n, width)
+			}
+			c := 0
+			for _, ch := range line {
+				c++
+				if ch == ' ' {
+					continue
+				}
+				if _, ok := options.TileMap[ch]; !ok {
+					return nil, p.errorf(c, "unknown tile %q", ch)
+				}
+			}
+		}
+
+		layer.Grid = grid
+		file.Layers = append(file.Layers, layer)
+	}
+
+	cols, rows := file.Size()
+	if spawn := options.Spawn; spawn != nil && (spawn.X >= cols || spawn.Y >= rows) {
+		p.lineNum = spawnLine
+		return nil, p.errorf(spawnCol, "spawn (%v, %v) is outside the %vx%v grid", spawn.X, spawn.Y, cols, rows)
+	}
+
+	return file, nil
+}
+
+func (p *fileParser) parsePlatform(fields []headerField, tileMap map[rune]Tile) (PlatformOptions, error) {
+	platform := PlatformOptions{Speed: 1, Rows: 1}
+	if len(fields) < 2 {
+		return platform, nil
+	}
+
+	ch, size := utf8.DecodeRuneInString(fields[0].value)
+	if size != len(fields[0].value) {
+		return platform, p.errorf(fields[0].col, "expected a single rune, got %q", fields[0].value)
+	}
+	tile, ok := tileMap[ch]
+	if !ok {
+		return platform, p.errorf(fields[0].col, "unknown tile %q, tiles must be declared before the platforms", ch)
+	}
+	platform.Tile = tile
+
+	cols, rows, hasRows := strings.Cut(fields[1].value, "x")
+	var err error
+	if platform.Cols, err = p.parseInt(headerField{cols, fields[1].col}, 1, 1<<16); err != nil {
+		return platform, err
+	}
+	if hasRows {
+		if platform.Rows, err = p.parseInt(headerField{rows, fields[1].col + len(cols) + 1}, 1, 1<<16); err != nil {
+			return platform, err
+		}
+	}
+
+	for _, field := range fields[2:] {
+		key, value, hasValue := strings.Cut(field.value, "=")
+		switch {
+		case field.value == "loop":
+			platform.Mode = PathLoop
+
+		case key == "speed" && hasValue:
+			speed, err := strconv.ParseFloat(value, 64)
+			if err != nil || speed < 0 {
+				return platform, p.errorf(field.col, "invalid speed %q", value)
+			}
+			platform.Speed = speed
+
+		case key == "name" && hasValue:
+			platform.Name = value
+
+		default:
+			c, r, ok := strings.Cut(field.value, ",")
+			if !ok {
+				return platform, p.errorf(field.col, "unknown platform option %q", field.value)
+			}
+			col, err := p.parseInt(headerField{c, field.col}, 0, 1<<16)
+			if err != nil {
+				return platform, err
+			}
+			row, err := p.parseInt(headerField{r, field.col + len(c) + 1}, 0, 1<<16)
+			if err != nil {
+				return platform, err
+			}
+			platform.Path = append(platform.Path, image.Point{X: col, Y: row})
+		}
+	}
+
+	return platform, nil
+}
+
+func (p *fileParser) parseObject(fields []headerField) (ObjectOptions, error) {
+	obj := ObjectOptions{Props: map[string]string{}}
+	if len(fields) < 2 {
+		return obj, nil
+	}
+	obj.Type = fields[0].value
+
+	c, r, ok := strings.Cut(fields[1].value, ",")
+	if !ok {
+		return obj, p.errorf(fields[1].col, "expected a column and a row, got %q", fields[1].value)
+	}
+	var err error
+	if obj.Col, err = p.parseInt(headerField{c, fields[1].col}, 0, 1<<16); err != nil {
+		return obj, err
+	}
+	if obj.Row, err = p.parseInt(headerField{r, fields[1].col + len(c) + 1}, 0, 1<<16); err != nil {
+		return obj, err
+	}
+
+	for _, field := range fields[2:] {
+		if key, value, ok := strings.Cut(field.value, "="); ok {
+			if key == "name" {
+				obj.Name = value
+			} else {
+				obj.Props[key] = value
+			}
+			continue
+		}
+		cols, rows, ok := strings.Cut(field.value, "x")
+		if !ok || obj.Cols > 0 {
+			return obj, p.errorf(field.col, "unknown object option %q", field.value)
+		}
+		if obj.Cols, err = p.parseInt(headerField{cols, field.col}, 1, 1<<16); err != nil {
+			return obj, err
+		}
+		if obj.Rows, err = p.parseInt(headerField{rows, field.col + len(cols) + 1}, 1, 1<<16); err != nil {
+			return obj, err
+		}
+	}
+
+	return obj, nil
+}
+
+func isLayerLine(trimmed string) bool {
+	return trimmed == "---" || strings.HasPrefix(trimmed, "--- ")
+}
+
+// parseLayerLine parses the name and options after the "---".
+func (p *fileParser) parseLayerLine(first bool) (FileLayer, error) {
+	layer := FileLayer{
+		Depth:    DepthMain,
+		Parallax: vector.Create(1, 1),
+	}
+
+	start := strings.Index(p.line, "---") + 3
+	fields := p.fields(p.line[start:], p.column(start))
+	collideSet := false
+
+	for i, field := range fields {
+		key, value, hasValue := strings.Cut(field.value, "=")
+		if i == 0 && !hasValue && key != "hidden" {
+			layer.Name = key
+			continue
+		}
+
+		switch key {
+		case "hidden":
+			layer.Hidden = true
+
+		case "depth":
+			depth, ok := ParseDepth(value)
+			if !ok {
+				return layer, p.errorf(field.col, "invalid depth %q, expected background, main or foreground", value)
+			}
+			layer.Depth = depth
+
+		case "collide":
+			collide, err := strconv.ParseBool(value)
+			if err != nil {
+				return layer, p.errorf(field.col, "invalid collide value %q", value)
+			}
+			layer.Collide = collide
+			collideSet = true
+
+		case "parallax":
+			x, y, hasY := strings.Cut(value, ",")
+			if !hasY {
+				y = x
+			}
+			px, errX := strconv.ParseFloat(x, 64)
+			py, errY := strconv.ParseFloat(y, 64)
+			if errX != nil || errY != nil {
+				return layer, p.errorf(field.col, "invalid parallax %q", value)
+			}
+			layer.Parallax = vector.Create(px, py)
+
+		default:
+			return layer, p.errorf(field.col, "unknown layer option %q", field.value)
+		}
+	}
+
+	if layer.Name == "" {
+		if !first {
+			return layer, p.errorf(1, "missing layer name")
+		}
+		layer.Name = "main"
+	}
+	if !collideSet {
+		layer.Collide = layer.Depth == DepthMain
+	}
+
+	return layer, nil
+}
+
+// Size returns the number of columns and rows of the largest layer.
+func (file *File) Size() (cols, rows int) {
+	for _, layer := range file.Layers {
+		if n := gridWidth(layer.Grid); cols < n {
+			cols = n
+		}
+		if rows < len(layer.Grid) {
+			rows = len(layer.Grid)
+		}
+	}
+	return
+}
+
+// NewLevelFromFile creates a level from a parsed level file.
+// The atlas and background images are loaded from the assets.
+func NewLevelFromFile(file *File) (*T, error) {
+	cols, rows := file.Size()
+
+	var layers []*Layer
+	for _, fileLayer := range file.Layers {
+		layer := NewLayer(fileLayer.Name, fileLayer.Depth, cols, rows)
+		layer.Visible = !fileLayer.Hidden
+		layer.Collide = fileLayer.Collide
+		layer.Parallax = fileLayer.Parallax
+		layer.fillGrid(file.Options.TileMap, fileLayer.Grid)
+		layers = append(layers, layer)
+	}
+
+	level, err := newLevelFromLayers(file.Options, cols, rows, layers)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", file.Name, err)
+	}
+	level.Name = file.Name
+	return level, nil
+}
+
+// LoadFile reads and parses a level file from fsys.
+// Tiled maps (.tmx and .tmj) are imported with LoadTiled.
+func LoadFile(fsys fs.FS, filename string) (*T, error) {
+	if isTiledFile(filename) {
+		return LoadTiled(fsys, filename)
+	}
+	data, err := fs.ReadFile(fsys, filename)
+	if err != nil {
+		return nil, err
+	}
+	return loadFile(filename, data)
+}
+
+// Load loads a level file from the disk, or from the
+// embedded assets if there is no such file on the disk.
+func Load(filename string) (*T, error) {
+	if isTiledFile(filename) {
+		if _, err := os.Stat(filename); err == nil {
+			return LoadTiled(os.DirFS(filepath.Dir(filename)), filepath.Base(filename))
+		}
+		return LoadTiled(assets.FS, filename)
+	}
+
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return LoadFile(assets.FS, filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return loadFile(filename, data)
+}
+
+func loadFile(filename string, data []byte) (*T, error) {
+	file, err := ParseFile(filename, data)
+	if err != nil {
+		return nil, err
+	}
+	return NewLevelFromFile(file)
+}
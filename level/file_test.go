@@ -0,0 +1,57 @@
+//go:build !nodisplay
+
+package level
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadFileErrors(t *testing.T) {
+	cases := []struct {
+		name      string
+		data      string
+		line, col int
+	}{
+		{"unknown rune", "atlas: lemcraft-tiles.png\ntile: # 14\n---\n##\n#?\n", 5, 2},
+		{"bad int", "atlas: lemcraft-tiles.png\ntilesize: 5o\n---\n#\n", 2, 11},
+		{"bad cols", "atlas: lemcraft-tiles.png\ntile: # 14\nplatform: # ax2 1,1\n---\n#\n", 3, 13},
+		{"bad rows", "atlas: lemcraft-tiles.png\ntile: # 14\nplatform: # 2xb 1,1\n---\n#\n", 3, 15},
+		{"bad object size", "atlas: lemcraft-tiles.png\nobject: goal 1,1 2x0\n---\n#\n", 2, 20},
+		{"two flag fields", "atlas: lemcraft-tiles.png\ntile: # 14 hazard slippery\n---\n#\n", 2, 19},
+		{"missing ---", "atlas: lemcraft-tiles.png\ntile: # 14\n", 3, 1},
+		{"short row", "atlas: lemcraft-tiles.png\ntile: # 14\n---\n###\n#\n###\n", 5, 2},
+		{"long row", "atlas: lemcraft-tiles.png\ntile: # 14\n---\n###\n####\n", 5, 4},
+	}
+	for _, c := range cases {
+		fsys := fstest.MapFS{"bad.level": {Data: []byte(c.data)}}
+		_, err := LoadFile(fsys, "bad.level")
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("%v: expected a parse error, got %v", c.name, err)
+			continue
+		}
+		if perr.Line != c.line || perr.Col != c.col {
+			t.Errorf("%v: expected an error on %v:%v, got %v", c.name, c.line, c.col, perr)
+		}
+	}
+}
+
+func TestLoadFileFlags(t *testing.T) {
+	fsys := fstest.MapFS{"flags.level": {Data: []byte(`
+atlas: lemcraft-tiles.png
+tile: # 14 hazard,slippery floor45r
+---
+#
+`)}}
+	level, err := LoadFile(fsys, "flags.level")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tile, _ := level.GetTileAt(0, 0)
+	expected := Tile{TileID: 14, Flags: FlagSolid | FlagHazard | FlagSlippery, Slope: SlopeFloor45R}
+	if tile != expected {
+		t.Errorf("expected %+v, got %+v", expected, tile)
+	}
+}
@@ -0,0 +1,85 @@
+package level
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Tile flags.
+const (
+	// FlagSolid tiles block the dino from every side.
+	FlagSolid uint16 = 1 << iota
+	// FlagOneWay tiles only block the dino when it lands on them from above.
+	// The dino can jump up through them, or drop through with down+space.
+	FlagOneWay
+	// FlagHazard tiles hurt the dino when touched.
+	FlagHazard
+	// FlagSlippery tiles make the dino slide when it stops walking.
+	// They are solid unless they're one-way.
+	FlagSlippery
+	// FlagBouncy tiles bounce the dino back up when it lands on them.
+	// They are solid unless they're one-way.
+	FlagBouncy
+	// FlagClimbable tiles can be climbed, like ladders and vines.
+	FlagClimbable
+)
+
+var flagNames = []struct {
+	flag uint16
+	name string
+}{
+	{FlagSolid, "solid"},
+	{FlagOneWay, "oneway"},
+	{FlagHazard, "hazard"},
+	{FlagSlippery, "slippery"},
+	{FlagBouncy, "bouncy"},
+	{FlagClimbable, "climbable"},
+}
+
+// ParseFlags parses either a number or names of flags separated
+// by commas or pipes, such as "solid,bouncy" or "oneway|slippery".
+// Named bouncy and slippery tiles are solid if they aren't one-way.
+func ParseFlags(s string) (uint16, error) {
+	if n, err := strconv.ParseUint(s, 0, 16); err == nil {
+		return uint16(n), nil
+	}
+
+	var flags uint16
+	names := strings.FieldsFunc(s, func(ch rune) bool { return ch == ',' || ch == '|' })
+	if len(names) == 0 {
+		return 0, fmt.Errorf("invalid tile flags %q", s)
+	}
+	for _, name := range names {
+		found := false
+		for _, f := range flagNames {
+			if f.name == strings.TrimSpace(name) {
+				flags |= f.flag
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown tile flag %q", name)
+		}
+	}
+	if flags&(FlagSlippery|FlagBouncy) != 0 && flags&FlagOneWay == 0 {
+		flags |= FlagSolid
+	}
+	return flags, nil
+}
+
+// FormatFlags returns the names of the flags separated by commas.
+func FormatFlags(flags uint16) string {
+	var names []string
+	for _, f := range flagNames {
+		if flags&f.flag != 0 {
+			names = append(names, f.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+func (tile Tile) Is(flags uint16) bool {
+	return tile.Flags&flags != 0
+}
@@ -0,0 +1,31 @@
+//go:build !nodisplay
+
+package level
+
+import "testing"
+
+func TestParseFlags(t *testing.T) {
+	cases := []struct {
+		s     string
+		flags uint16
+	}{
+		{"solid", FlagSolid},
+		{"oneway|hazard", FlagOneWay | FlagHazard},
+		{"bouncy", FlagBouncy | FlagSolid},
+		{"slippery", FlagSlippery | FlagSolid},
+		{"oneway,bouncy", FlagOneWay | FlagBouncy},
+		{"16", FlagBouncy},
+	}
+	for _, c := range cases {
+		flags, err := ParseFlags(c.s)
+		if err != nil {
+			t.Errorf("%q: %v", c.s, err)
+		} else if flags != c.flags {
+			t.Errorf("%q: expected %v, got %v", c.s, FormatFlags(c.flags), FormatFlags(flags))
+		}
+	}
+
+	if _, err := ParseFlags("sticky"); err == nil {
+		t.Errorf("expected an error for an unknown flag")
+	}
+}
@@ -0,0 +1,101 @@
+package level
+
+import "github.com/nvlled/dinojump/vector"
+
+type Depth int
+
+const (
+	DepthBackground Depth = iota
+	DepthMain
+	DepthForeground
+)
+
+var depthNames = map[Depth]string{
+	DepthBackground: "background",
+	DepthMain:       "main",
+	DepthForeground: "foreground",
+}
+
+func (depth Depth) String() string {
+	return depthNames[depth]
+}
+
+// ParseDepth returns the depth with the given name.
+func ParseDepth(name string) (Depth, bool) {
+	for depth, s := range depthNames {
+		if s == name {
+			return depth, true
+		}
+	}
+	return 0, false
+}
+
+// Layer is a grid of tiles with the same size as the level.
+// Background and main layers are drawn behind the dino,
+// foreground layers are drawn in front of it.
+type Layer struct {
+	Name    string
+	Depth   Depth
+	Visible bool
+
+	// Collide is true if the tiles of the layer
+	// are used in the collision checks.
+	Collide bool
+
+	// Parallax is how fast the layer scrolls relative to the view,
+	// (1, 1) scrolls with the view and (0, 0) doesn't scroll at all.
+	Parallax vector.T
+
+	cols int
+	data []Tile
+}
+
+// NewLayer creates an empty layer. Only the main layers collide by default.
+func NewLayer(name string, depth Depth, cols, rows int) *Layer {
+	data := make([]Tile, cols*rows)
+	for i := range data {
+		data[i] = Tile{TileID: -1}
+	}
+	return &Layer{
+		Name:     name,
+		Depth:    depth,
+		Visible:  true,
+		Collide:  depth == DepthMain,
+		Parallax: vector.Create(1, 1),
+		cols:     cols,
+		data:     data,
+	}
+}
+
+func (layer *Layer) fillGrid(tileMap map[rune]Tile, lines []string) {
+	for r, line := range lines {
+		for c, ch := range []rune(line) {
+			if tile, ok := tileMap[ch]; ok {
+				layer.SetTileAt(c, r, tile)
+			}
+		}
+	}
+}
+
+// GetTileAt returns the tile on column c and row r,
+// or false if it's outside of the layer.
+func (layer *Layer) GetTileAt(c, r int) (Tile, bool) {
+	if c < 0 || c >= layer.cols {
+		return Tile{}, false
+	}
+	i := r*layer.cols + c
+	if i < 0 || i >= len(layer.data) {
+		return Tile{}, false
+	}
+	return layer.data[i], true
+}
+
+func (layer *Layer) SetTileAt(c, r int, tile Tile) {
+	if c < 0 || c >= layer.cols {
+		return
+	}
+	i := r*layer.cols + c
+	if i >= 0 && i < len(layer.data) {
+		layer.data[i] = tile
+	}
+}
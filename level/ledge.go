@@ -0,0 +1,65 @@
+package level
+
+import (
+	"math"
+
+	"github.com/nvlled/dinojump/rect"
+	"github.com/nvlled/dinojump/vector"
+)
+
+// Ledge is the top corner of a solid tile that can be grabbed.
+type Ledge struct {
+	// Corner is the top corner of the tile on the side of the rect.
+	Corner vector.T
+	// Dir is the side of the rect the ledge is on,
+	// -1 for the left and 1 for the right.
+	Dir f64
+}
+
+// FindLedge returns the ledge next to the side of the rect in dir, whose
+// top is at most reach above the top of the rect. The tile of a ledge is
+// solid and not sloped, and there are no solid tiles above it up to the
+// height of the rect, so that the rect can climb onto it.
+// Platforms don't have ledges.
+func (level *T) FindLedge(r *rect.T, dir f64, reach f64) (Ledge, bool) {
+	size := f64(level.RenderTileSize)
+	x := r.Right() + ContactDistance
+	if dir < 0 {
+		x = r.Left() - ContactDistance
+	}
+	c := int(math.Floor(x / size))
+
+	first, last := level.span(r.Top()-reach, r.Top()+ContactDistance)
+	for row := first; row <= last; row++ {
+		top := f64(row) * size
+		if top < r.Top()-reach || top > r.Top() {
+			continue
+		}
+		tile := level.tileAt(c, row)
+		if tile.Flags&FlagSolid == 0 || tile.Slope != SlopeNone {
+			continue
+		}
+		if !level.isClear(c, top-r.Height(), top) {
+			continue
+		}
+
+		corner := vector.Create(f64(c)*size, top)
+		if dir < 0 {
+			corner.X += size
+		}
+		return Ledge{Corner: corner, Dir: dir}, true
+	}
+	return Ledge{}, false
+}
+
+// isClear returns true if there are no solid tiles
+// on column c from top to bottom.
+func (level *T) isClear(c int, top, bottom f64) bool {
+	first, last := level.span(top, bottom)
+	for row := first; row <= last; row++ {
+		if level.tileAt(c, row).Flags&FlagSolid != 0 {
+			return false
+		}
+	}
+	return true
+}
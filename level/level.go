@@ -1,7 +1,10 @@
 package level
 
 import (
+	"image"
+	"sort"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
@@ -13,9 +16,16 @@ import (
 
 type f64 = float64
 
+const DefaultRenderTileSize = 50
+
 type Tile struct {
 	TileID int
 	Flags  uint16
+	Slope  Slope
+
+	// Flip is 0b10 for horizontal flip, 0b01 for vertical flip
+	// and 0b100 for diagonal flip (swapping x and y axes).
+	Flip byte
 }
 
 type TilePos struct {
@@ -24,11 +34,21 @@ type TilePos struct {
 }
 
 type T struct {
+	Name           string
 	RenderTileSize int
 
 	rows int
 	cols int
-	data []Tile
+
+	// Layers are sorted by depth, from the back to the front.
+	Layers []*Layer
+
+	Platforms []*Platform
+	Objects   []*Object
+
+	// DrawLag is how far back from their last move the
+	// platforms are drawn, for drawing in between fixed updates.
+	DrawLag f64
 
 	Atlas *sprite.T
 
@@ -37,14 +57,25 @@ type T struct {
 
 type NewOptions struct {
 	AtlasFilename      string
+	AtlasCols          int
+	AtlasRows          int
 	BackgroundFilename string
 
 	TileMap        map[rune]Tile
 	RenderTileSize int
+
+	// Spawn is the column and row of the tile where the dino starts,
+	// added as a spawn object before the other objects.
+	Spawn *image.Point
+
+	Platforms []PlatformOptions
+	Objects   []ObjectOptions
 }
 
+// CreateTile creates a tile with the given flags,
+// or a solid tile if there are no flags.
 func CreateTile(id int, flagsOpt ...uint16) Tile {
-	var flags uint16 = 0
+	var flags uint16 = FlagSolid
 	if len(flagsOpt) > 0 {
 		flags = flagsOpt[0]
 	}
@@ -55,52 +86,88 @@ func CreateTile(id int, flagsOpt ...uint16) Tile {
 }
 
 func NewLevel(options NewOptions, levelData string) *T {
-	img, _, err := ebitenutil.NewImageFromFileSystem(assets.FS, options.AtlasFilename)
+	levelData = strings.TrimSpace(levelData)
+	lines := strings.Split(levelData, "\n")
+
+	level, err := newLevel(options, lines)
 	if err != nil {
 		panic(err)
 	}
+	return level
+}
 
-	sprite := sprite.New(img, 7, 8)
-
-	levelData = strings.TrimSpace(levelData)
-	lines := strings.Split(levelData, "\n")
+func newLevel(options NewOptions, lines []string) (*T, error) {
 	rows := len(lines)
-	cols := 0
+	cols := gridWidth(lines)
+
+	layer := NewLayer("main", DepthMain, cols, rows)
+	layer.fillGrid(options.TileMap, lines)
+
+	return newLevelFromLayers(options, cols, rows, []*Layer{layer})
+}
 
+func gridWidth(lines []string) int {
+	cols := 0
 	for _, line := range lines {
-		if cols < len(line) {
-			cols = len(line)
+		if n := utf8.RuneCountInString(line); cols < n {
+			cols = n
 		}
 	}
-	data := make([]Tile, rows*cols)
+	return cols
+}
 
-	for r := 0; r < rows; r++ {
-		for c := 0; c < cols; c++ {
-			index := r*cols + c
-			data[index] = Tile{TileID: -1}
-		}
+// newLevelFromLayers creates a level from layers of cols*rows tiles.
+func newLevelFromLayers(options NewOptions, cols, rows int, layers []*Layer) (*T, error) {
+	atlasCols, atlasRows := options.AtlasCols, options.AtlasRows
+	if atlasCols <= 0 || atlasRows <= 0 {
+		atlasCols, atlasRows = 7, 8
 	}
 
-	for r, line := range lines {
-		for c, ch := range line {
-			tile, ok := options.TileMap[ch]
-			if ok {
-				data[r*cols+c] = tile
-			}
+	img, _, err := ebitenutil.NewImageFromFileSystem(assets.FS, options.AtlasFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	var bgImage *ebiten.Image
+	if options.BackgroundFilename != "" {
+		bgImage, _, err = ebitenutil.NewImageFromFileSystem(assets.FS, options.BackgroundFilename)
+		if err != nil {
+			return nil, err
 		}
 	}
 
+	sprite := sprite.New(img, atlasCols, atlasRows)
+
 	level := &T{
 		RenderTileSize: options.RenderTileSize,
 		rows:           rows,
 		cols:           cols,
 		Atlas:          sprite,
-		data:           data,
+		Layers:         layers,
 
-		bgImage: ebitenx.NewImageFromAssets(options.BackgroundFilename),
+		bgImage: bgImage,
 	}
 
-	return level
+	sort.SliceStable(level.Layers, func(i, j int) bool {
+		return level.Layers[i].Depth < level.Layers[j].Depth
+	})
+
+	for _, platform := range options.Platforms {
+		level.Platforms = append(level.Platforms, level.newPlatform(platform))
+	}
+
+	if options.Spawn != nil {
+		level.Objects = append(level.Objects, level.newObject(ObjectOptions{
+			Type: ObjectSpawn,
+			Col:  options.Spawn.X,
+			Row:  options.Spawn.Y,
+		}))
+	}
+	for _, obj := range options.Objects {
+		level.Objects = append(level.Objects, level.newObject(obj))
+	}
+
+	return level, nil
 }
 
 /*
@@ -173,20 +240,48 @@ func (level *T) HasTileOn(x, y f64) bool {
 	return tile.TileID >= 0
 }
 
+// HasTileAt returns true if any of the collision layers
+// has a solid tile on column c and row r.
 func (level *T) HasTileAt(c, r int) bool {
-	i := r*level.cols + c
-	if i < 0 || i >= len(level.data) {
-		return false
-	}
-	return level.data[i].TileID >= 0
+	tile, _ := level.GetTileAt(c, r)
+	return tile.TileID >= 0 && tile.Is(FlagSolid)
 }
 
+// GetTileAt returns the tile of the front-most collision layer that
+// has a solid tile on column c and row r, or that has any tile if none
+// of them are solid. The flags of the tile are those of all the collision
+// tiles there, so a non-solid tile doesn't hide a solid one behind it.
+// It returns false if the column and row are outside of the level.
 func (level *T) GetTileAt(c, r int) (Tile, bool) {
 	i := r*level.cols + c
-	if i < 0 || i >= len(level.data) {
+	if i < 0 || i >= level.cols*level.rows {
 		return Tile{}, false
 	}
-	return level.data[i], true
+	result := Tile{TileID: -1}
+	var flags uint16
+	for l := len(level.Layers) - 1; l >= 0; l-- {
+		layer := level.Layers[l]
+		tile := layer.data[i]
+		if !layer.Collide || tile.TileID < 0 {
+			continue
+		}
+		if result.TileID < 0 || (!result.Is(FlagSolid) && tile.Is(FlagSolid)) {
+			result = tile
+		}
+		flags |= tile.Flags
+	}
+	result.Flags = flags
+	return result, true
+}
+
+// GetLayer returns the layer with the given name, or nil if there's none.
+func (level *T) GetLayer(name string) *Layer {
+	for _, layer := range level.Layers {
+		if layer.Name == name {
+			return layer
+		}
+	}
+	return nil
 }
 
 func (level *T) GetTileRectAt(c, r int) rect.T {
@@ -220,14 +315,41 @@ func (level *T) drawBackground(canvas *ebiten.Image) {
 	ebitenx.DrawImageAtRect(canvas, level.bgImage, &rect)
 }
 
+// Draw draws the background, the layers behind the dino and the platforms.
 func (level *T) Draw(canvas *ebiten.Image, view *rect.T) {
+	level.drawBackground(canvas)
+	for _, layer := range level.Layers {
+		if layer.Depth < DepthForeground {
+			level.drawLayer(canvas, view, layer)
+		}
+	}
+	level.drawPlatforms(canvas, view)
+}
+
+// DrawForeground draws the layers in front of the dino.
+func (level *T) DrawForeground(canvas *ebiten.Image, view *rect.T) {
+	for _, layer := range level.Layers {
+		if layer.Depth >= DepthForeground {
+			level.drawLayer(canvas, view, layer)
+		}
+	}
+}
+
+func (level *T) drawLayer(canvas *ebiten.Image, view *rect.T, layer *Layer) {
+	if !layer.Visible {
+		return
+	}
+
 	sprite := level.Atlas
 	tileSize := level.RenderTileSize
 
-	level.drawBackground(canvas)
+	// The canvas is in world coordinates, so a layer that scrolls
+	// slower than the view is shifted along with the view.
+	offsetX := view.Min.X * (1 - layer.Parallax.X)
+	offsetY := view.Min.Y * (1 - layer.Parallax.Y)
 
-	ac, ar := view.Min.XY_int()
-	bc, br := view.Max.XY_int()
+	ac, ar := int(view.Min.X-offsetX), int(view.Min.Y-offsetY)
+	bc, br := int(view.Max.X-offsetX), int(view.Max.Y-offsetY)
 
 	size := level.RenderTileSize
 	ac, ar = ac/size, ar/size
@@ -241,13 +363,21 @@ func (level *T) Draw(canvas *ebiten.Image, view *rect.T) {
 			}
 
 			index := r*level.cols + c
-			tile := level.data[index]
+			tile := layer.data[index]
 			if tile.TileID < 0 {
 				continue
 			}
 			tileImg := sprite.GetTileImage(tile.TileID)
-			destRect.SetTopLeftXY(f64(c*tileSize), float64(r*tileSize))
-			ebitenx.DrawImageAtRect(canvas, tileImg, &destRect)
+			destRect.SetTopLeftXY(f64(c*tileSize)+offsetX, float64(r*tileSize)+offsetY)
+			if tile.Flip == 0 {
+				ebitenx.DrawImageAtRect(canvas, tileImg, &destRect)
+			} else {
+				op := ebiten.GeoM{}
+				ebitenx.TransformImageFlipDiagonal(tileImg, &op, tile.Flip)
+				ebitenx.TransformImageFlip(tileImg, &op, tile.Flip&0b11)
+				ebitenx.TransformImageRect(tileImg, &destRect, &op)
+				canvas.DrawImage(tileImg, &ebiten.DrawImageOptions{GeoM: op})
+			}
 		}
 	}
 }
@@ -0,0 +1,46 @@
+//go:build !nodisplay
+
+package level
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestGetTileAtLayers(t *testing.T) {
+	fsys := fstest.MapFS{"layers.level": {Data: []byte(`
+atlas: lemcraft-tiles.png
+atlas-size: 7 8
+tile: # 14
+tile: ~ 30 0
+tile: x 2 hazard
+---
+ ##
+--- front depth=main
+ ~x~
+`)}}
+	level, err := LoadFile(fsys, "layers.level")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		c     int
+		tile  Tile
+		solid bool
+	}{
+		{0, Tile{TileID: -1}, false},
+		{1, Tile{TileID: 14, Flags: FlagSolid}, true},
+		{2, Tile{TileID: 14, Flags: FlagSolid | FlagHazard}, true},
+		{3, Tile{TileID: 30}, false},
+	}
+	for _, c := range cases {
+		tile, ok := level.GetTileAt(c.c, 0)
+		if !ok || tile != c.tile {
+			t.Errorf("column %v: expected %+v, got %+v", c.c, c.tile, tile)
+		}
+		if solid := level.HasTileAt(c.c, 0); solid != c.solid {
+			t.Errorf("column %v: expected HasTileAt to be %v", c.c, c.solid)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package level
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/nvlled/dinojump/assets"
+)
+
+// A manifest lists the level files in the order they are played,
+// one per line, relative to the directory of the manifest.
+//
+//	# comments start with #
+//	level1.level
+//	level2.tmx
+
+// ReadManifest reads a manifest from the disk, or from the
+// embedded assets if there is no such file on the disk,
+// and returns the level filenames.
+func ReadManifest(filename string) ([]string, error) {
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		data, err = fs.ReadFile(assets.FS, filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseManifest(filename, data)
+}
+
+func ParseManifest(filename string, data []byte) ([]string, error) {
+	dir := path.Dir(filepath.ToSlash(filename))
+	var result []string
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if path.IsAbs(line) {
+			return nil, &ParseError{Filename: filename, Line: i + 1, Col: 1, Msg: "level paths must be relative to the manifest"}
+		}
+		result = append(result, path.Join(dir, line))
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("%v: the manifest has no levels", filename)
+	}
+	return result, nil
+}
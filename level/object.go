@@ -0,0 +1,133 @@
+package level
+
+import (
+	"strconv"
+
+	"github.com/nvlled/dinojump/rect"
+	"github.com/nvlled/dinojump/vector"
+)
+
+// Object types used by the game.
+// Levels may have objects of any other type.
+const (
+	ObjectSpawn      = "spawn"
+	ObjectCheckpoint = "checkpoint"
+	ObjectGoal       = "goal"
+	ObjectTrigger    = "trigger"
+	ObjectEnemy      = "enemy"
+)
+
+// Object is a typed region of the level, like where the
+// dino starts or where an enemy spawns.
+type Object struct {
+	Type  string
+	Name  string
+	Rect  rect.T
+	Props map[string]string
+}
+
+// ObjectOptions describes an object in columns and rows,
+// for creating levels with NewOptions.
+type ObjectOptions struct {
+	Type  string
+	Name  string
+	Col   int
+	Row   int
+	Cols  int
+	Rows  int
+	Props map[string]string
+}
+
+func (obj *Object) Prop(key, def string) string {
+	if value, ok := obj.Props[key]; ok {
+		return value
+	}
+	return def
+}
+
+func (obj *Object) PropFloat(key string, def f64) f64 {
+	if n, err := strconv.ParseFloat(obj.Props[key], 64); err == nil {
+		return n
+	}
+	return def
+}
+
+func (obj *Object) PropBool(key string, def bool) bool {
+	if b, err := strconv.ParseBool(obj.Props[key]); err == nil {
+		return b
+	}
+	return def
+}
+
+// ObjectsOfType returns the objects of the given type, in the order they were added.
+func (level *T) ObjectsOfType(typ string) []*Object {
+	var result []*Object
+	for _, obj := range level.Objects {
+		if obj.Type == typ {
+			result = append(result, obj)
+		}
+	}
+	return result
+}
+
+// ObjectsIn returns the objects that intersect with r.
+func (level *T) ObjectsIn(r *rect.T) []*Object {
+	var result []*Object
+	for _, obj := range level.Objects {
+		if obj.Rect.Intersects(r) {
+			result = append(result, obj)
+		}
+	}
+	return result
+}
+
+// FindObject returns the first object of the given type, or nil if there's none.
+func (level *T) FindObject(typ string) *Object {
+	for _, obj := range level.Objects {
+		if obj.Type == typ {
+			return obj
+		}
+	}
+	return nil
+}
+
+// FindObjectByName returns the object with the given name, or nil if there's none.
+func (level *T) FindObjectByName(name string) *Object {
+	for _, obj := range level.Objects {
+		if obj.Name == name {
+			return obj
+		}
+	}
+	return nil
+}
+
+// SpawnPoint returns the middle of the first spawn object,
+// or false if the level has none.
+func (level *T) SpawnPoint() (vector.T, bool) {
+	obj := level.FindObject(ObjectSpawn)
+	if obj == nil {
+		return vector.Zero, false
+	}
+	return obj.Rect.Mid(), true
+}
+
+func (level *T) newObject(options ObjectOptions) *Object {
+	size := f64(level.RenderTileSize)
+	cols, rows := options.Cols, options.Rows
+	if cols <= 0 {
+		cols = 1
+	}
+	if rows <= 0 {
+		rows = 1
+	}
+	props := options.Props
+	if props == nil {
+		props = map[string]string{}
+	}
+	return &Object{
+		Type:  options.Type,
+		Name:  options.Name,
+		Rect:  rect.Create(f64(options.Col)*size, f64(options.Row)*size, f64(cols)*size, f64(rows)*size),
+		Props: props,
+	}
+}
@@ -0,0 +1,254 @@
+package level
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/nvlled/dinojump/ebitenx"
+	"github.com/nvlled/dinojump/rect"
+	"github.com/nvlled/dinojump/vector"
+)
+
+// PathMode is how a platform goes through its path.
+type PathMode byte
+
+const (
+	// PathPingPong goes to the last waypoint and back to the first.
+	PathPingPong PathMode = iota
+
+	// PathLoop goes back to the first waypoint after the last one.
+	PathLoop
+)
+
+func (mode PathMode) String() string {
+	switch mode {
+	case PathPingPong:
+		return "pingpong"
+	case PathLoop:
+		return "loop"
+	}
+	return fmt.Sprintf("PathMode(%d)", mode)
+}
+
+func ParsePathMode(s string) (PathMode, bool) {
+	switch s {
+	case "pingpong":
+		return PathPingPong, true
+	case "loop":
+		return PathLoop, true
+	}
+	return 0, false
+}
+
+// Platform is a solid or one-way rect that moves along a path
+// of waypoints, and collides like the tiles of the level.
+type Platform struct {
+	Name string
+	Rect rect.T
+
+	// Tile is drawn over the rect, and its flags
+	// are the flags of the platform.
+	Tile Tile
+
+	// Path are the positions of the top left of the platform.
+	Path  []vector.T
+	Mode  PathMode
+	Speed f64
+
+	// Delta is how much the platform moved on the last update.
+	Delta vector.T
+
+	next int
+	dir  int
+}
+
+// PlatformOptions describes a platform in columns and rows,
+// for creating levels with NewOptions.
+type PlatformOptions struct {
+	Name  string
+	Tile  Tile
+	Cols  int
+	Rows  int
+	Path  []image.Point
+	Mode  PathMode
+	Speed f64
+}
+
+func NewPlatform(tile Tile, r rect.T, path []vector.T, mode PathMode, speed f64) *Platform {
+	platform := &Platform{
+		Rect:  r,
+		Tile:  tile,
+		Path:  path,
+		Mode:  mode,
+		Speed: speed,
+	}
+	platform.Reset()
+	return platform
+}
+
+// Reset moves the platform back to the first waypoint.
+func (platform *Platform) Reset() {
+	platform.next = 0
+	platform.dir = 1
+	platform.Delta = vector.Zero
+	if len(platform.Path) > 0 {
+		platform.Rect.SetTopLeftXY(platform.Path[0].XY())
+		platform.advance()
+	}
+}
+
+func (platform *Platform) Update() {
+	start := platform.Rect.Min
+	pos := start
+	remaining := platform.Speed
+
+	// Guards against paths where all the waypoints are the same.
+	for i := 0; i < 2*len(platform.Path) && remaining > 0 && len(platform.Path) > 1; i++ {
+		target := platform.Path[platform.next]
+		d := vector.Sub(&target, &pos)
+		dist := d.Length()
+		if dist > remaining {
+			d.Scale(remaining / dist)
+			pos.Add(&d)
+			break
+		}
+		pos = target
+		remaining -= dist
+		platform.advance()
+	}
+
+	platform.Rect.SetTopLeftXY(pos.XY())
+	platform.Delta = vector.Sub(&pos, &start)
+}
+
+func (platform *Platform) advance() {
+	n := len(platform.Path)
+	if n < 2 {
+		return
+	}
+	switch platform.Mode {
+	case PathLoop:
+		platform.next = (platform.next + 1) % n
+	default:
+		if next := platform.next + platform.dir; next < 0 || next >= n {
+			platform.dir = -platform.dir
+		}
+		platform.next += platform.dir
+	}
+}
+
+// PlatformState is the part of a platform that changes as it moves.
+type PlatformState struct {
+	Pos   vector.T
+	Delta vector.T
+	Next  int
+	Dir   int
+}
+
+func (platform *Platform) State() PlatformState {
+	return PlatformState{
+		Pos:   platform.Rect.Min,
+		Delta: platform.Delta,
+		Next:  platform.next,
+		Dir:   platform.dir,
+	}
+}
+
+func (platform *Platform) SetState(state PlatformState) {
+	platform.Rect.SetTopLeftXY(state.Pos.XY())
+	platform.Delta = state.Delta
+	platform.next = state.Next
+	platform.dir = state.Dir
+}
+
+func (platform *Platform) Is(flags uint16) bool {
+	return platform.Tile.Is(flags)
+}
+
+// Update moves the platforms of the level.
+func (level *T) Update() {
+	for _, platform := range level.Platforms {
+		platform.Update()
+	}
+}
+
+// Reset moves the platforms of the level back to their start.
+func (level *T) Reset() {
+	for _, platform := range level.Platforms {
+		platform.Reset()
+	}
+}
+
+// PlatformStates returns the states of the platforms, in the same order.
+func (level *T) PlatformStates() []PlatformState {
+	var states []PlatformState
+	for _, platform := range level.Platforms {
+		states = append(states, platform.State())
+	}
+	return states
+}
+
+// SetPlatformStates sets the states of the platforms, returning
+// false if the number of states doesn't match the platforms.
+func (level *T) SetPlatformStates(states []PlatformState) bool {
+	if len(states) != len(level.Platforms) {
+		return false
+	}
+	for i, platform := range level.Platforms {
+		platform.SetState(states[i])
+	}
+	return true
+}
+
+// PlatformIndex returns the index of the platform in the level, or -1.
+func (level *T) PlatformIndex(platform *Platform) int {
+	for i, p := range level.Platforms {
+		if p == platform {
+			return i
+		}
+	}
+	return -1
+}
+
+// PlatformAt returns the platform at the index, or nil if it's out of range.
+func (level *T) PlatformAt(index int) *Platform {
+	if index < 0 || index >= len(level.Platforms) {
+		return nil
+	}
+	return level.Platforms[index]
+}
+
+func (level *T) newPlatform(options PlatformOptions) *Platform {
+	size := f64(level.RenderTileSize)
+	var path []vector.T
+	for _, p := range options.Path {
+		path = append(path, vector.Create(f64(p.X)*size, f64(p.Y)*size))
+	}
+	r := rect.Create(0, 0, f64(options.Cols)*size, f64(options.Rows)*size)
+	platform := NewPlatform(options.Tile, r, path, options.Mode, options.Speed)
+	platform.Name = options.Name
+	return platform
+}
+
+func (level *T) drawPlatforms(canvas *ebiten.Image, view *rect.T) {
+	size := f64(level.RenderTileSize)
+	for _, platform := range level.Platforms {
+		if platform.Tile.TileID < 0 || !platform.Rect.Intersects(view) {
+			continue
+		}
+		tileImg := level.Atlas.GetTileImage(platform.Tile.TileID)
+		r := platform.Rect
+		lag := platform.Delta.Scaled(-level.DrawLag)
+		r.Min.Add(&lag)
+		r.Max.Add(&lag)
+		destRect := rect.Create(0, 0, size, size)
+		for y := r.Top(); y < r.Bottom()-sweepEpsilon; y += size {
+			for x := r.Left(); x < r.Right()-sweepEpsilon; x += size {
+				destRect.SetTopLeftXY(math.Round(x), math.Round(y))
+				ebitenx.DrawImageAtRect(canvas, tileImg, &destRect)
+			}
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package level
+
+import "fmt"
+
+// Slope is the shape of a sloped tile. Floor slopes are solid below
+// the slope and ceiling slopes are solid above it. The 22.5° slopes
+// span two tiles, the lower half (1) followed by the upper half (2).
+type Slope byte
+
+const (
+	SlopeNone Slope = iota
+
+	// Floors rising to the right, like /
+	SlopeFloor45R
+	SlopeFloor22R1
+	SlopeFloor22R2
+
+	// Floors rising to the left, like \
+	SlopeFloor45L
+	SlopeFloor22L1
+	SlopeFloor22L2
+
+	// Ceilings that are the vertical mirrors of the floors above.
+	SlopeCeil45R
+	SlopeCeil22R1
+	SlopeCeil22R2
+	SlopeCeil45L
+	SlopeCeil22L1
+	SlopeCeil22L2
+)
+
+type slopeShape struct {
+	name string
+
+	// left and right are the heights of the solid part
+	// on the edges of the tile, from 0 to 1.
+	left  f64
+	right f64
+
+	ceiling bool
+}
+
+var slopeShapes = [...]slopeShape{
+	SlopeNone: {name: "none"},
+
+	SlopeFloor45R:  {"floor45r", 0, 1, false},
+	SlopeFloor22R1: {"floor22r1", 0, 0.5, false},
+	SlopeFloor22R2: {"floor22r2", 0.5, 1, false},
+	SlopeFloor45L:  {"floor45l", 1, 0, false},
+	SlopeFloor22L1: {"floor22l1", 0.5, 0, false},
+	SlopeFloor22L2: {"floor22l2", 1, 0.5, false},
+
+	SlopeCeil45R:  {"ceil45r", 0, 1, true},
+	SlopeCeil22R1: {"ceil22r1", 0, 0.5, true},
+	SlopeCeil22R2: {"ceil22r2", 0.5, 1, true},
+	SlopeCeil45L:  {"ceil45l", 1, 0, true},
+	SlopeCeil22L1: {"ceil22l1", 0.5, 0, true},
+	SlopeCeil22L2: {"ceil22l2", 1, 0.5, true},
+}
+
+func (slope Slope) String() string {
+	if int(slope) >= len(slopeShapes) {
+		return fmt.Sprintf("Slope(%d)", slope)
+	}
+	return slopeShapes[slope].name
+}
+
+// ParseSlope returns the slope with the given name, e.g. "floor45r".
+func ParseSlope(name string) (Slope, error) {
+	for i, shape := range slopeShapes {
+		if shape.name == name {
+			return Slope(i), nil
+		}
+	}
+	return SlopeNone, fmt.Errorf("unknown slope %q", name)
+}
+
+func (slope Slope) IsFloor() bool {
+	return slope != SlopeNone && !slopeShapes[slope].ceiling
+}
+
+func (slope Slope) IsCeiling() bool {
+	return slope != SlopeNone && slopeShapes[slope].ceiling
+}
+
+// FlipH returns the slope mirrored horizontally.
+func (slope Slope) FlipH() Slope {
+	shape := slopeShapes[slope]
+	return findSlope(shape.right, shape.left, shape.ceiling, slope)
+}
+
+// FlipV returns the slope mirrored vertically.
+func (slope Slope) FlipV() Slope {
+	shape := slopeShapes[slope]
+	return findSlope(shape.left, shape.right, !shape.ceiling, slope)
+}
+
+// FlipD returns the slope mirrored along the diagonal from the top left
+// to the bottom right, which swaps the x and y axes. Only the 45° slopes
+// have a mirrored shape, the 22.5° slopes would become too steep.
+func (slope Slope) FlipD() (Slope, bool) {
+	switch slope {
+	case SlopeNone, SlopeFloor45R, SlopeCeil45L:
+		return slope, true
+	case SlopeFloor45L:
+		return SlopeCeil45R, true
+	case SlopeCeil45R:
+		return SlopeFloor45L, true
+	}
+	return slope, false
+}
+
+func findSlope(left, right f64, ceiling bool, def Slope) Slope {
+	if def == SlopeNone {
+		return SlopeNone
+	}
+	for i, shape := range slopeShapes {
+		if Slope(i) != SlopeNone && shape.left == left && shape.right == right && shape.ceiling == ceiling {
+			return Slope(i)
+		}
+	}
+	return def
+}
+
+// height returns the height of the solid part at fx,
+// from 0 on the left edge of the tile to 1 on the right edge.
+func (slope Slope) height(fx f64) f64 {
+	shape := slopeShapes[slope]
+	if fx < 0 {
+		fx = 0
+	} else if fx > 1 {
+		fx = 1
+	}
+	return shape.left + (shape.right-shape.left)*fx
+}
+
+// surfaceY returns the y of the slope surface at x, for the
+// tile on column c and row r. That's the top of the solid part
+// for floors, and the bottom of the solid part for ceilings.
+func (level *T) surfaceY(slope Slope, c, r int, x f64) f64 {
+	size := f64(level.RenderTileSize)
+	h := slope.height((x - f64(c)*size) / size)
+	if slope.IsCeiling() {
+		return f64(r)*size + size*h
+	}
+	return f64(r)*size + size*(1-h)
+}
+
+// solidSpan returns the top and bottom of the solid part on an edge of the
+// tile on column c and row r, where fx is 0 for the left edge and 1 for the right.
+// The span is empty if top >= bottom.
+func (level *T) solidSpan(tile Tile, c, r int, fx f64) (top, bottom f64) {
+	size := f64(level.RenderTileSize)
+	top, bottom = f64(r)*size, f64(r+1)*size
+	switch {
+	case tile.Slope.IsFloor():
+		top = bottom - size*tile.Slope.height(fx)
+	case tile.Slope.IsCeiling():
+		bottom = top + size*tile.Slope.height(fx)
+	}
+	return
+}
@@ -0,0 +1,507 @@
+package level
+
+import (
+	"math"
+
+	"github.com/nvlled/dinojump/rect"
+	"github.com/nvlled/dinojump/vector"
+)
+
+const sweepEpsilon = 1e-6
+
+// ContactDistance is how close a side of a rect has to be
+// to a tile to be in contact with it.
+const ContactDistance = 1.0
+
+// SweepResult is the result of moving a rect through the tiles of a level.
+type SweepResult struct {
+	// Rect is the rect at the resolved position.
+	Rect rect.T
+
+	// Normal is the normal of the tiles that blocked the movement on each axis.
+	// X is 1 if blocked by a tile on the left, -1 on the right, and Y is 1
+	// if blocked by a tile above, -1 below. It's zero when not blocked.
+	Normal vector.T
+
+	// TimeX and TimeY are the fractions of the movement done on each axis
+	// before the rect was blocked, or 1 if it wasn't blocked.
+	TimeX f64
+	TimeY f64
+
+	// Hit are the sides of the resolved rect that are in contact with a tile:
+	// 0b1000 for the left, 0b0100 right, 0b0010 top and 0b0001 bottom.
+	Hit byte
+
+	// Ground are the flags of the tiles the rect stands on.
+	Ground uint16
+
+	// Touch are the flags of the tiles the rect overlaps or is in contact with,
+	// including the ones that don't block it.
+	Touch uint16
+
+	// Platform is the platform the rect stands on, if any.
+	Platform *Platform
+}
+
+type SweepOptions struct {
+	// DropThrough makes the rect fall through one-way tiles.
+	DropThrough bool
+
+	// StepHeight is how high a step the rect walks up onto.
+	// Tiles in this height from the bottom of the rect don't
+	// block it on the x axis. Used for walking up slopes.
+	StepHeight f64
+
+	// SnapDistance is how far down the rect is moved to stay on the
+	// ground when it's not moving up. Used for walking down slopes.
+	SnapDistance f64
+}
+
+// Time is the fraction of the movement before the first hit.
+func (res *SweepResult) Time() f64 {
+	return math.Min(res.TimeX, res.TimeY)
+}
+
+// Sweep moves the rect by vel, first on the x axis then on the y axis,
+// stopping at the first tile that blocks it on each axis so it can't pass
+// through tiles no matter how fast it moves. Tiles that the rect already
+// overlaps don't block it.
+//
+// Solid tiles block from every side. One-way tiles only block the rect
+// when it moves down onto them, unless opts.DropThrough is true.
+// Sloped tiles only block the rect on their solid part, and the rect
+// stands on floor slopes with the middle of its bottom side.
+// Platforms block the rect like tiles, from where they were
+// before their last update, so they can't move through it.
+// Solid platforms that moved into a side of the rect push it first.
+func (level *T) Sweep(r *rect.T, vel vector.T, opts SweepOptions) SweepResult {
+	res := SweepResult{
+		Rect:  *r,
+		TimeX: 1,
+		TimeY: 1,
+	}
+
+	if push := level.platformPush(&res.Rect, opts.StepHeight); push != 0 {
+		top, bottom := level.sideSpan(&res.Rect, opts.StepHeight)
+		move, _ := level.sweepTilesX(&res.Rect, push, top, bottom)
+		res.Rect.Min.X += move
+		res.Rect.Max.X += move
+	}
+
+	if vel.X != 0 {
+		move, blocked := level.sweepX(&res.Rect, vel.X, opts.StepHeight)
+		res.Rect.Min.X += move
+		res.Rect.Max.X += move
+		if blocked {
+			res.TimeX = math.Max(0, move/vel.X)
+			res.Normal.X = -math.Copysign(1, vel.X)
+		}
+	}
+
+	if vel.Y < 0 {
+		move, blocked := level.sweepUp(&res.Rect, vel.Y)
+		res.Rect.Min.Y += move
+		res.Rect.Max.Y += move
+		if blocked {
+			res.TimeY = math.Max(0, move/vel.Y)
+			res.Normal.Y = 1
+		}
+	} else {
+		level.sweepDown(&res, vel, opts)
+	}
+
+	level.findContacts(&res, opts)
+
+	return res
+}
+
+// Contacts returns the sides of the rect that are in contact with a tile,
+// without moving it.
+func (level *T) Contacts(r *rect.T, dropThrough bool) SweepResult {
+	return level.Sweep(r, vector.Zero, SweepOptions{DropThrough: dropThrough})
+}
+
+func (level *T) sweepX(r *rect.T, dx f64, step f64) (move f64, blocked bool) {
+	top, bottom := level.sideSpan(r, step)
+	move, blocked = level.sweepTilesX(r, dx, top, bottom)
+
+	for _, platform := range level.Platforms {
+		p := &platform.Rect
+		if !platform.Is(FlagSolid) || !overlaps(p.Top(), p.Bottom(), top, bottom) {
+			continue
+		}
+		if dx > 0 {
+			left := math.Max(p.Left(), p.Left()-platform.Delta.X)
+			if left >= r.Right()-sweepEpsilon && p.Left()-r.Right() < move {
+				move, blocked = p.Left()-r.Right(), true
+			}
+		} else {
+			right := math.Min(p.Right(), p.Right()-platform.Delta.X)
+			if right <= r.Left()+sweepEpsilon && p.Right()-r.Left() > move {
+				move, blocked = p.Right()-r.Left(), true
+			}
+		}
+	}
+
+	return
+}
+
+// platformPush returns how far the solid platforms that moved
+// into a side of the rect on their last update push it.
+func (level *T) platformPush(r *rect.T, step f64) (push f64) {
+	top, bottom := level.sideSpan(r, step)
+	for _, platform := range level.Platforms {
+		p := &platform.Rect
+		dx := platform.Delta.X
+		if !platform.Is(FlagSolid) || !overlaps(p.Top(), p.Bottom(), top, bottom) {
+			continue
+		}
+		if dx > 0 && p.Right()-dx <= r.Left()+sweepEpsilon && p.Right() > r.Left() {
+			push = math.Max(push, p.Right()-r.Left())
+		} else if dx < 0 && p.Left()-dx >= r.Right()-sweepEpsilon && p.Left() < r.Right() {
+			push = math.Min(push, p.Left()-r.Right())
+		}
+	}
+	return
+}
+
+func (level *T) sweepTilesX(r *rect.T, dx f64, top, bottom f64) (move f64, blocked bool) {
+	size := f64(level.RenderTileSize)
+	r0, r1 := level.span(top, bottom)
+
+	if dx > 0 {
+		edge := r.Right()
+		from := int(math.Ceil(edge/size - sweepEpsilon))
+		to := int(math.Floor((edge+dx)/size - sweepEpsilon))
+		for c := from; c <= to; c++ {
+			for row := r0; row <= r1; row++ {
+				if level.blocksSide(c, row, 0, top, bottom) {
+					return f64(c)*size - edge, true
+				}
+			}
+		}
+	} else {
+		edge := r.Left()
+		from := int(math.Floor(edge/size+sweepEpsilon)) - 1
+		to := int(math.Floor((edge+dx)/size + sweepEpsilon))
+		for c := from; c >= to; c-- {
+			for row := r0; row <= r1; row++ {
+				if level.blocksSide(c, row, 1, top, bottom) {
+					return f64(c+1)*size - edge, true
+				}
+			}
+		}
+	}
+
+	return dx, false
+}
+
+func (level *T) sweepUp(r *rect.T, dy f64) (move f64, blocked bool) {
+	top := r.Top()
+	if y, _, ok := level.findCeiling(r, top, top+dy); ok {
+		return y - top, true
+	}
+	return dy, false
+}
+
+func (level *T) sweepDown(res *SweepResult, vel vector.T, opts SweepOptions) {
+	r := &res.Rect
+	dy := vel.Y
+	bottom := r.Bottom()
+
+	// Slopes can rise up to the distance moved on the x axis,
+	// so the rect is moved up onto them from that far below.
+	flatFrom := bottom - opts.StepHeight
+	slopeFrom := flatFrom - math.Abs(vel.X)
+	to := bottom + dy + opts.SnapDistance
+
+	move := dy
+	if y, _, _, ok := level.findFloor(r, flatFrom, slopeFrom, to, opts.DropThrough); ok {
+		if y < bottom+dy-sweepEpsilon {
+			res.Normal.Y = -1
+			res.TimeY = 0
+			if dy > 0 {
+				res.TimeY = math.Max(0, (y-bottom)/dy)
+			}
+		}
+		move = y - bottom
+	}
+
+	r.Min.Y += move
+	r.Max.Y += move
+}
+
+// findFloor returns the highest floor below the rect from the heights from
+// to to. Slopes are checked under the middle of the rect, from the height slopeFrom.
+// The platform is the floor if it's a platform.
+func (level *T) findFloor(r *rect.T, from, slopeFrom, to f64, dropThrough bool) (y f64, flags uint16, platform *Platform, ok bool) {
+	size := f64(level.RenderTileSize)
+
+	var slopeY f64
+	var slopeFlags uint16
+	hasSlope := false
+
+	mid := r.MidX()
+	cm := int(math.Floor(mid / size))
+	for row := int(math.Floor(slopeFrom / size)); row <= int(math.Floor(to/size)); row++ {
+		tile := level.tileAt(cm, row)
+		if !tile.Slope.IsFloor() || !isFloor(tile.Flags, dropThrough) {
+			continue
+		}
+		y := level.surfaceY(tile.Slope, cm, row, mid)
+		if y >= slopeFrom-sweepEpsilon && y <= to+sweepEpsilon {
+			slopeY, slopeFlags, hasSlope = y, tile.Flags, true
+			break
+		}
+	}
+
+	bottom := r.Bottom()
+	c0, c1 := level.span(r.Left(), r.Right())
+	for row := int(math.Ceil(from/size - sweepEpsilon)); row <= int(math.Floor(to/size+sweepEpsilon)); row++ {
+		top := f64(row) * size
+		for c := c0; c <= c1; c++ {
+			// On a slope, only the tiles under the middle of the
+			// rect are checked so it follows the slope to the end.
+			if hasSlope && c != cm {
+				continue
+			}
+			tile := level.tileAt(c, row)
+			// Ceiling slopes have a flat top.
+			if tile.Slope.IsFloor() || !isFloor(tile.Flags, dropThrough) {
+				continue
+			}
+			// One-way tiles never push the rect up.
+			if tile.Flags&FlagSolid == 0 && top < bottom-sweepEpsilon {
+				continue
+			}
+			y, ok = top, true
+			flags |= tile.Flags
+		}
+		if ok {
+			break
+		}
+	}
+
+	if hasSlope && (!ok || slopeY < y) {
+		y, flags, ok = slopeY, slopeFlags, true
+	}
+
+	for _, pf := range level.Platforms {
+		p := &pf.Rect
+		if !isFloor(pf.Tile.Flags, dropThrough) || !overlaps(p.Left(), p.Right(), r.Left(), r.Right()) {
+			continue
+		}
+		limit := from
+		if !pf.Is(FlagSolid) {
+			limit = bottom
+		}
+		top := math.Max(p.Top(), p.Top()-pf.Delta.Y)
+		if top < limit-sweepEpsilon || p.Top() > to+sweepEpsilon {
+			continue
+		}
+		if !ok || p.Top() < y {
+			y, flags, platform, ok = p.Top(), pf.Tile.Flags, pf, true
+		}
+	}
+
+	return
+}
+
+// findCeiling returns the lowest ceiling above the rect from the heights from to to.
+// Slopes are checked above the middle of the rect.
+func (level *T) findCeiling(r *rect.T, from, to f64) (y f64, flags uint16, ok bool) {
+	size := f64(level.RenderTileSize)
+	y = math.Inf(-1)
+
+	c0, c1 := level.span(r.Left(), r.Right())
+	for row := int(math.Floor(from/size+sweepEpsilon)) - 1; row >= int(math.Floor(to/size+sweepEpsilon)); row-- {
+		for c := c0; c <= c1; c++ {
+			tile := level.tileAt(c, row)
+			// Floor slopes have a flat bottom.
+			if !tile.Slope.IsCeiling() && tile.Flags&FlagSolid != 0 {
+				y, ok = f64(row+1)*size, true
+				flags |= tile.Flags
+			}
+		}
+		if ok {
+			break
+		}
+	}
+
+	mid := r.MidX()
+	cm := int(math.Floor(mid / size))
+	for row := int(math.Floor(from / size)); row >= int(math.Floor(to/size)); row-- {
+		tile := level.tileAt(cm, row)
+		if !tile.Slope.IsCeiling() || tile.Flags&FlagSolid == 0 {
+			continue
+		}
+		sy := level.surfaceY(tile.Slope, cm, row, mid)
+		if sy <= from+sweepEpsilon && sy >= to-sweepEpsilon && sy > y {
+			y, flags, ok = sy, tile.Flags, true
+			break
+		}
+	}
+
+	for _, platform := range level.Platforms {
+		p := &platform.Rect
+		if !platform.Is(FlagSolid) || !overlaps(p.Left(), p.Right(), r.Left(), r.Right()) {
+			continue
+		}
+		bottom := math.Min(p.Bottom(), p.Bottom()-platform.Delta.Y)
+		if bottom > from+sweepEpsilon || p.Bottom() < to-sweepEpsilon {
+			continue
+		}
+		if p.Bottom() > y {
+			y, flags, ok = p.Bottom(), platform.Tile.Flags, true
+		}
+	}
+
+	return
+}
+
+func (level *T) findContacts(res *SweepResult, opts SweepOptions) {
+	size := f64(level.RenderTileSize)
+	r := &res.Rect
+	c0, c1 := level.span(r.Left(), r.Right())
+	r0, r1 := level.span(r.Top(), r.Bottom())
+
+	for row := r0; row <= r1; row++ {
+		for c := c0; c <= c1; c++ {
+			res.Touch |= level.flagsAt(c, row)
+		}
+	}
+
+	top, bottom := level.sideSpan(r, opts.StepHeight)
+	s0, s1 := level.span(top, bottom)
+
+	if c := int(math.Floor(r.Left()/size+sweepEpsilon)) - 1; r.Left()-f64(c+1)*size <= ContactDistance {
+		for row := s0; row <= s1; row++ {
+			res.Touch |= level.flagsAt(c, row)
+			if level.blocksSide(c, row, 1, top, bottom) {
+				res.Hit |= 0b1000
+			}
+		}
+	}
+
+	if c := int(math.Ceil(r.Right()/size - sweepEpsilon)); f64(c)*size-r.Right() <= ContactDistance {
+		for row := s0; row <= s1; row++ {
+			res.Touch |= level.flagsAt(c, row)
+			if level.blocksSide(c, row, 0, top, bottom) {
+				res.Hit |= 0b0100
+			}
+		}
+	}
+
+	if _, flags, ok := level.findCeiling(r, r.Top(), r.Top()-ContactDistance); ok {
+		res.Hit |= 0b0010
+		res.Touch |= flags
+	}
+
+	if row := int(math.Ceil(r.Bottom()/size - sweepEpsilon)); f64(row)*size-r.Bottom() <= ContactDistance {
+		for c := c0; c <= c1; c++ {
+			res.Touch |= level.flagsAt(c, row)
+		}
+	}
+
+	for _, platform := range level.Platforms {
+		p := &platform.Rect
+		if !overlaps(p.Top(), p.Bottom(), top, bottom) {
+			continue
+		}
+		if d := r.Left() - p.Right(); d >= -sweepEpsilon && d <= ContactDistance {
+			res.Touch |= platform.Tile.Flags
+			if platform.Is(FlagSolid) {
+				res.Hit |= 0b1000
+			}
+		}
+		if d := p.Left() - r.Right(); d >= -sweepEpsilon && d <= ContactDistance {
+			res.Touch |= platform.Tile.Flags
+			if platform.Is(FlagSolid) {
+				res.Hit |= 0b0100
+			}
+		}
+	}
+
+	from := r.Bottom()
+	if _, flags, platform, ok := level.findFloor(r, from, from-ContactDistance, from+ContactDistance, opts.DropThrough); ok {
+		res.Hit |= 0b0001
+		res.Ground |= flags
+		res.Touch |= flags
+		res.Platform = platform
+	}
+}
+
+// overlaps returns true if the ranges [a0, a1] and [b0, b1] overlap.
+func overlaps(a0, a1, b0, b1 f64) bool {
+	return a0 < b1-sweepEpsilon && a1 > b0+sweepEpsilon
+}
+
+// sideSpan returns the top and bottom of the side of the rect
+// that is checked on the x axis, leaving out the step height.
+func (level *T) sideSpan(r *rect.T, step f64) (top, bottom f64) {
+	top, bottom = r.Top(), r.Bottom()-step
+	if bottom-top < 1 {
+		bottom = top + 1
+	}
+	return
+}
+
+// blocksSide returns true if the tile on column c and row r blocks
+// a side from top to bottom, from the edge fx of the tile (0 for the
+// left edge and 1 for the right).
+func (level *T) blocksSide(c, r int, fx f64, top, bottom f64) bool {
+	tile := level.tileAt(c, r)
+	if tile.Flags&FlagSolid == 0 {
+		return false
+	}
+	t, b := level.solidSpan(tile, c, r, fx)
+	return t < b && t < bottom-sweepEpsilon && b > top+sweepEpsilon
+}
+
+func isFloor(flags uint16, dropThrough bool) bool {
+	return flags&FlagSolid != 0 || (flags&FlagOneWay != 0 && !dropThrough)
+}
+
+// span returns the first and last tile index covered by the range [a, b).
+func (level *T) span(a, b f64) (first, last int) {
+	size := f64(level.RenderTileSize)
+	first = int(math.Floor(a/size + sweepEpsilon))
+	last = int(math.Floor(b/size - sweepEpsilon))
+	if last < first {
+		last = first
+	}
+	return
+}
+
+// tileAt returns the collision tile on column c and row r, with
+// no flags if there's no tile or if it's outside of the level.
+func (level *T) tileAt(c, r int) Tile {
+	if c < 0 || c >= level.cols || r < 0 || r >= level.rows {
+		return Tile{TileID: -1}
+	}
+	tile, _ := level.GetTileAt(c, r)
+	if tile.TileID < 0 {
+		return Tile{TileID: -1}
+	}
+	return tile
+}
+
+// flagsAt returns the flags of the collision tile on column c and row r,
+// or zero if there's no tile or if it's outside of the level.
+func (level *T) flagsAt(c, r int) uint16 {
+	return level.tileAt(c, r).Flags
+}
+
+// FlagsIn returns the flags of all the tiles that overlap the rect.
+func (level *T) FlagsIn(r *rect.T) uint16 {
+	c0, c1 := level.span(r.Left(), r.Right())
+	r0, r1 := level.span(r.Top(), r.Bottom())
+
+	var flags uint16
+	for row := r0; row <= r1; row++ {
+		for c := c0; c <= c1; c++ {
+			flags |= level.flagsAt(c, row)
+		}
+	}
+	return flags
+}
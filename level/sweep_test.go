@@ -0,0 +1,142 @@
+//go:build !nodisplay
+
+package level
+
+import (
+	"math"
+	"testing"
+	"testing/fstest"
+
+	"github.com/nvlled/dinojump/rect"
+	"github.com/nvlled/dinojump/vector"
+)
+
+func TestPlatformPush(t *testing.T) {
+	fsys := fstest.MapFS{"push.level": {Data: []byte(`
+atlas: lemcraft-tiles.png
+atlas-size: 7 8
+tilesize: 50
+tile: # 14
+tile: . 30 0
+platform: # 1 1,1 5,1 speed=10
+---
+       .
+       .
+########
+`)}}
+	level, err := LoadFile(fsys, "push.level")
+	if err != nil {
+		t.Fatal(err)
+	}
+	level.Reset()
+
+	// Standing on the ground, 5 pixels right of the platform.
+	r := rect.Create(105, 75, 25, 25)
+	level.Update()
+
+	res := level.Sweep(&r, vector.Zero, SweepOptions{})
+	if res.Rect.Left() != 110 || res.Hit != 0b1001 {
+		t.Errorf("expected to be pushed to 110 with the platform on the left, got %v with hit %04b", res.Rect.Left(), res.Hit)
+	}
+
+	// Walking back into the platform doesn't go through it.
+	r = res.Rect
+	level.Update()
+	res = level.Sweep(&r, vector.Create(-5, 0), SweepOptions{})
+	if res.Rect.Left() != 120 {
+		t.Errorf("expected to be pushed to 120, got %v", res.Rect.Left())
+	}
+}
+
+func TestSweep(t *testing.T) {
+	fsys := fstest.MapFS{"sweep.level": {Data: []byte(`
+atlas: lemcraft-tiles.png
+atlas-size: 7 8
+tilesize: 50
+tile: # 14
+tile: - 12 oneway
+tile: . 30 0
+---
+..........
+.....#....
+.--..#....
+.......#..
+##########
+`)}}
+	level, err := LoadFile(fsys, "sweep.level")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		rect   rect.T
+		vel    vector.T
+		opts   SweepOptions
+		pos    vector.T
+		normal vector.T
+		timeX  f64
+		timeY  f64
+		hit    byte
+	}{
+		{
+			// At the speed of the jump charge dash, the rect would end up
+			// in the wall if only the end position was checked.
+			name: "dash into a wall",
+			rect: rect.Create(205, 60, 25, 25), vel: vector.Create(70, 0),
+			pos: vector.Create(225, 60), normal: vector.Create(-1, 0),
+			timeX: 20.0 / 70, timeY: 1, hit: 0b0100,
+		},
+		{
+			name: "diagonal into a corner",
+			rect: rect.Create(300, 150, 25, 25), vel: vector.Create(40, 40),
+			pos: vector.Create(325, 175), normal: vector.Create(-1, -1),
+			timeX: 25.0 / 40, timeY: 25.0 / 40, hit: 0b0101,
+		},
+		{
+			// The sides of the floor tiles don't snag the rect.
+			name: "along the floor",
+			rect: rect.Create(50, 175, 25, 25), vel: vector.Create(30, 1),
+			pos: vector.Create(80, 175), normal: vector.Create(0, -1),
+			timeX: 1, timeY: 0, hit: 0b0001,
+		},
+		{
+			name: "up through a one-way tile",
+			rect: rect.Create(60, 160, 25, 25), vel: vector.Create(0, -30),
+			pos: vector.Create(60, 130), normal: vector.Zero,
+			timeX: 1, timeY: 1, hit: 0,
+		},
+		{
+			name: "down onto a one-way tile",
+			rect: rect.Create(60, 70, 25, 25), vel: vector.Create(0, 20),
+			pos: vector.Create(60, 75), normal: vector.Create(0, -1),
+			timeX: 1, timeY: 0.25, hit: 0b0001,
+		},
+		{
+			name: "dropping through a one-way tile",
+			rect: rect.Create(60, 70, 25, 25), vel: vector.Create(0, 20),
+			opts: SweepOptions{DropThrough: true},
+			pos:  vector.Create(60, 90), normal: vector.Zero,
+			timeX: 1, timeY: 1, hit: 0,
+		},
+	}
+	for _, c := range cases {
+		res := level.Sweep(&c.rect, c.vel, c.opts)
+		if pos := res.Rect.Min; !near(pos.X, c.pos.X) || !near(pos.Y, c.pos.Y) {
+			t.Errorf("%v: expected to end at %v, got %v", c.name, c.pos, pos)
+		}
+		if res.Normal != c.normal {
+			t.Errorf("%v: expected the normal %v, got %v", c.name, c.normal, res.Normal)
+		}
+		if !near(res.TimeX, c.timeX) || !near(res.TimeY, c.timeY) {
+			t.Errorf("%v: expected the times %v and %v, got %v and %v", c.name, c.timeX, c.timeY, res.TimeX, res.TimeY)
+		}
+		if res.Hit != c.hit {
+			t.Errorf("%v: expected hit %04b, got %04b", c.name, c.hit, res.Hit)
+		}
+	}
+}
+
+func near(a, b f64) bool {
+	return math.Abs(a-b) < 1e-9
+}
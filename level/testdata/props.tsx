@@ -0,0 +1,9 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" name="props" tilewidth="50" tileheight="50" tilecount="1" columns="0">
+ <tile id="0">
+  <properties>
+   <property name="atlas_id" type="int" value="1"/>
+   <property name="flags" value="0"/>
+  </properties>
+ </tile>
+</tileset>
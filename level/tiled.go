@@ -0,0 +1,988 @@
+package level
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/nvlled/dinojump/assets"
+	"github.com/nvlled/dinojump/rect"
+	"github.com/nvlled/dinojump/vector"
+)
+
+// Flip bits that are stored in the upper bits of a Tiled GID.
+const (
+	tiledFlipH    uint32 = 0x80000000
+	tiledFlipV    uint32 = 0x40000000
+	tiledFlipD    uint32 = 0x20000000
+	tiledRotate   uint32 = 0x10000000
+	tiledFlipMask        = tiledFlipH | tiledFlipV | tiledFlipD | tiledRotate
+)
+
+// TiledMap is a map made with the Tiled editor (https://www.mapeditor.org),
+// read from either a .tmx (XML) or a .tmj (JSON) file.
+type TiledMap struct {
+	Width      int
+	Height     int
+	TileWidth  int
+	TileHeight int
+
+	Properties map[string]string
+
+	Tilesets     []TiledTileset
+	TileLayers   []TiledTileLayer
+	ObjectLayers []TiledObjectLayer
+}
+
+type TiledTileset struct {
+	FirstGID  int
+	Name      string
+	Image     string
+	Columns   int
+	TileCount int
+
+	// TileProperties are the custom properties of the tiles, keyed by local tile ID.
+	TileProperties map[int]map[string]string
+}
+
+type TiledTileLayer struct {
+	Name       string
+	Visible    bool
+	ParallaxX  float64
+	ParallaxY  float64
+	Properties map[string]string
+
+	// GIDs are the global tile IDs of the layer, row by row,
+	// including the flip bits.
+	GIDs []uint32
+}
+
+type TiledObjectLayer struct {
+	Name       string
+	Visible    bool
+	Properties map[string]string
+	Objects    []TiledObject
+}
+
+type TiledObject struct {
+	ID     int
+	Name   string
+	Type   string
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+	GID    uint32
+	Points []vector.T
+
+	Properties map[string]string
+}
+
+// Rect returns the bounds of the object. Tile objects
+// are positioned from their bottom-left corner in Tiled.
+func (obj *TiledObject) Rect() (x, y, w, h float64) {
+	x, y, w, h = obj.X, obj.Y, obj.Width, obj.Height
+	if obj.GID != 0 {
+		y -= h
+	}
+	return
+}
+
+// LoadTiled reads a Tiled map from fsys and creates a level out of it.
+// External tilesets are read relative to the map file.
+func LoadTiled(fsys fs.FS, filename string) (*T, error) {
+	m, err := ReadTiledMap(fsys, filename)
+	if err != nil {
+		return nil, err
+	}
+	level, err := NewLevelFromTiled(m)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", filename, err)
+	}
+	level.Name = filename
+	return level, nil
+}
+
+func isTiledFile(filename string) bool {
+	ext := path.Ext(filename)
+	return ext == ".tmx" || ext == ".tmj"
+}
+
+// ReadTiledMap reads a .tmx or .tmj map from fsys.
+func ReadTiledMap(fsys fs.FS, filename string) (*TiledMap, error) {
+	data, err := fs.ReadFile(fsys, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var m *TiledMap
+	if path.Ext(filename) == ".tmx" {
+		m, err = parseTMX(fsys, filename, data)
+	} else {
+		m, err = parseTMJ(fsys, filename, data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", filename, err)
+	}
+	return m, nil
+}
+
+// NewLevelFromTiled creates a level from the tile layers of the map.
+// The "depth" layer property is one of background, main or foreground,
+// and defaults to main. The "collide" layer property sets whether
+// the layer is used for collisions, which is only true for the main
+// layers by default.
+//
+// The first tileset with an image is used as the level atlas,
+// and the GIDs are mapped to the atlas tile IDs by their local ID
+// in the tileset. A tile can be mapped to another atlas tile
+// with an "atlas_id" property. Tile flags are read from the
+// "flags" property of the tiles, and tiles without one are solid.
+// Sloped tiles have a "slope" property (see ParseSlope), which is
+// flipped along with the tile. The 22.5° slopes can't be flipped
+// diagonally, or rotated by 90°.
+//
+// The "background" and "tilesize" map properties set the level
+// background and render tile size. The objects are added to the
+// level objects with their type (or class) and properties, and an
+// object named or typed "spawn" sets where the dino starts.
+//
+// Objects typed "platform" are moving platforms, drawn with their tile
+// or the "atlas_id" property, and with the tile flags or the "flags"
+// property. The "path" property is the ID or name of a polyline whose
+// points are the top left of the platform on each waypoint. The "speed"
+// property is in map pixels per tick, and "loop" makes it go back to
+// the first waypoint instead of going back and forth.
+func NewLevelFromTiled(m *TiledMap) (*T, error) {
+	var atlas *TiledTileset
+	for i := range m.Tilesets {
+		if m.Tilesets[i].Image != "" {
+			atlas = &m.Tilesets[i]
+			break
+		}
+	}
+	if atlas == nil {
+		return nil, fmt.Errorf("the map has no tileset with an image")
+	}
+	if atlas.Columns <= 0 {
+		return nil, fmt.Errorf("tileset %q has no columns", atlas.Name)
+	}
+
+	options := NewOptions{
+		AtlasFilename:      atlas.Image,
+		AtlasCols:          atlas.Columns,
+		AtlasRows:          (atlas.TileCount + atlas.Columns - 1) / atlas.Columns,
+		BackgroundFilename: m.Properties["background"],
+		RenderTileSize:     DefaultRenderTileSize,
+	}
+	if value, ok := m.Properties["tilesize"]; ok {
+		size, err := strconv.Atoi(value)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid tilesize property %q", value)
+		}
+		options.RenderTileSize = size
+	}
+
+	var layers []*Layer
+	for _, tiledLayer := range m.TileLayers {
+		if len(tiledLayer.GIDs) != m.Width*m.Height {
+			return nil, fmt.Errorf("layer %q has %v tiles, expected %v", tiledLayer.Name, len(tiledLayer.GIDs), m.Width*m.Height)
+		}
+
+		depth := DepthMain
+		if value, ok := tiledLayer.Properties["depth"]; ok {
+			if depth, ok = ParseDepth(value); !ok {
+				return nil, fmt.Errorf("layer %q: invalid depth %q", tiledLayer.Name, value)
+			}
+		}
+
+		layer := NewLayer(tiledLayer.Name, depth, m.Width, m.Height)
+		layer.Visible = tiledLayer.Visible
+		layer.Parallax = vector.Create(tiledLayer.ParallaxX, tiledLayer.ParallaxY)
+		if value, ok := tiledLayer.Properties["collide"]; ok {
+			collide, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("layer %q: invalid collide %q", tiledLayer.Name, value)
+			}
+			layer.Collide = collide
+		}
+
+		for i, gid := range tiledLayer.GIDs {
+			if gid&^tiledFlipMask == 0 {
+				continue
+			}
+			tile, err := m.tile(gid, atlas)
+			if err != nil {
+				return nil, fmt.Errorf("layer %q, column %v, row %v: %w", tiledLayer.Name, i%m.Width, i/m.Width, err)
+			}
+			layer.data[i] = tile
+		}
+		layers = append(layers, layer)
+	}
+
+	level, err := newLevelFromLayers(options, m.Width, m.Height, layers)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.TileWidth <= 0 || m.TileHeight <= 0 {
+		return level, nil
+	}
+	scale := vector.Create(
+		f64(level.RenderTileSize)/f64(m.TileWidth),
+		f64(level.RenderTileSize)/f64(m.TileHeight),
+	)
+
+	for i := range m.ObjectLayers {
+		for j := range m.ObjectLayers[i].Objects {
+			obj := &m.ObjectLayers[i].Objects[j]
+			if obj.Type != "platform" {
+				level.Objects = append(level.Objects, m.object(obj, scale))
+				continue
+			}
+			platform, err := m.platform(obj, atlas, scale)
+			if err != nil {
+				return nil, fmt.Errorf("platform %v: %w", obj.ID, err)
+			}
+			level.Platforms = append(level.Platforms, platform)
+		}
+	}
+
+	return level, nil
+}
+
+func (m *TiledMap) object(obj *TiledObject, scale vector.T) *Object {
+	typ := obj.Type
+	if typ == "" && obj.Name == ObjectSpawn {
+		typ = ObjectSpawn
+	}
+	props := map[string]string{}
+	for key, value := range obj.Properties {
+		props[key] = value
+	}
+	x, y, w, h := obj.Rect()
+	return &Object{
+		Type:  typ,
+		Name:  obj.Name,
+		Rect:  rect.Create(x*scale.X, y*scale.Y, w*scale.X, h*scale.Y),
+		Props: props,
+	}
+}
+
+func (m *TiledMap) platform(obj *TiledObject, atlas *TiledTileset, scale vector.T) (*Platform, error) {
+	props := obj.Properties
+
+	tile := Tile{TileID: -1, Flags: FlagSolid}
+	if obj.GID != 0 {
+		var err error
+		if tile, err = m.tile(obj.GID, atlas); err != nil {
+			return nil, err
+		}
+	}
+	if value, ok := props["atlas_id"]; ok {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid atlas_id %q", value)
+		}
+		tile.TileID = n
+	}
+	if value, ok := props["flags"]; ok {
+		flags, err := ParseFlags(value)
+		if err != nil {
+			return nil, err
+		}
+		tile.Flags = flags
+	}
+
+	x, y, w, h := obj.Rect()
+	r := rect.Create(x*scale.X, y*scale.Y, w*scale.X, h*scale.Y)
+
+	path := []vector.T{r.Min}
+	if value, ok := props["path"]; ok {
+		line := m.findObjectByRef(value)
+		if line == nil || len(line.Points) == 0 {
+			return nil, fmt.Errorf("path %q is not a polyline", value)
+		}
+		path = nil
+		for _, p := range line.Points {
+			path = append(path, vector.Create((line.X+p.X)*scale.X, (line.Y+p.Y)*scale.Y))
+		}
+	}
+
+	mode := PathPingPong
+	if value, ok := props["loop"]; ok {
+		loop, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid loop %q", value)
+		}
+		if loop {
+			mode = PathLoop
+		}
+	}
+
+	speed := parseFloat(props["speed"], 1) * scale.X
+
+	platform := NewPlatform(tile, r, path, mode, speed)
+	platform.Name = obj.Name
+	return platform, nil
+}
+
+// findObjectByRef returns the object with the given ID or name.
+func (m *TiledMap) findObjectByRef(ref string) *TiledObject {
+	id, err := strconv.Atoi(ref)
+	for i := range m.ObjectLayers {
+		for j := range m.ObjectLayers[i].Objects {
+			obj := &m.ObjectLayers[i].Objects[j]
+			if (err == nil && obj.ID == id) || obj.Name == ref {
+				return obj
+			}
+		}
+	}
+	return nil
+}
+
+func (m *TiledMap) tileset(gid int) *TiledTileset {
+	var result *TiledTileset
+	for i := range m.Tilesets {
+		ts := &m.Tilesets[i]
+		if ts.FirstGID <= gid && (result == nil || ts.FirstGID > result.FirstGID) {
+			result = ts
+		}
+	}
+	return result
+}
+
+func (m *TiledMap) tile(gid uint32, atlas *TiledTileset) (Tile, error) {
+	id := int(gid &^ tiledFlipMask)
+	ts := m.tileset(id)
+	if ts == nil {
+		return Tile{}, fmt.Errorf("GID %v does not belong to any tileset", id)
+	}
+
+	localID := id - ts.FirstGID
+	props := ts.TileProperties[localID]
+
+	tile := Tile{TileID: localID}
+	if value, ok := props["atlas_id"]; ok {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return Tile{}, fmt.Errorf("invalid atlas_id %q", value)
+		}
+		tile.TileID = n
+	} else if ts.Image != atlas.Image {
+		return Tile{}, fmt.Errorf("tileset %q does not use the atlas image, and tile %v has no atlas_id", ts.Name, localID)
+	}
+
+	tile.Flags = FlagSolid
+	if value, ok := props["flags"]; ok {
+		flags, err := ParseFlags(value)
+		if err != nil {
+			return Tile{}, err
+		}
+		tile.Flags = flags
+	}
+	if value, ok := props["slope"]; ok {
+		slope, err := ParseSlope(value)
+		if err != nil {
+			return Tile{}, err
+		}
+		tile.Slope = slope
+	}
+
+	// Tiled flips diagonally first, then horizontally and vertically.
+	if gid&tiledFlipD != 0 {
+		slope, ok := tile.Slope.FlipD()
+		if !ok {
+			return Tile{}, fmt.Errorf("slope %v can't be flipped diagonally", tile.Slope)
+		}
+		tile.Flip |= 0b100
+		tile.Slope = slope
+	}
+	if gid&tiledFlipH != 0 {
+		tile.Flip |= 0b10
+		tile.Slope = tile.Slope.FlipH()
+	}
+	if gid&tiledFlipV != 0 {
+		tile.Flip |= 0b01
+		tile.Slope = tile.Slope.FlipV()
+	}
+
+	return tile, nil
+}
+
+// resolveImage returns the name of the image in the assets,
+// given the image source relative to dir.
+func resolveImage(dir, source string) string {
+	filename := path.Clean(path.Join(dir, source))
+	if _, err := fs.Stat(assets.FS, filename); err == nil {
+		return filename
+	}
+	return path.Base(source)
+}
+
+// decodeGIDs decodes the tile data of a layer.
+func decodeGIDs(encoding, compression, data string) ([]uint32, error) {
+	switch encoding {
+	case "csv":
+		var gids []uint32
+		for _, field := range strings.Split(data, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			n, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tile GID %q", field)
+			}
+			gids = append(gids, uint32(n))
+		}
+		return gids, nil
+
+	case "base64":
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 tile data: %w", err)
+		}
+
+		var r io.Reader = bytes.NewReader(raw)
+		switch compression {
+		case "":
+		case "zlib":
+			if r, err = zlib.NewReader(r); err != nil {
+				return nil, fmt.Errorf("invalid zlib tile data: %w", err)
+			}
+		case "gzip":
+			if r, err = gzip.NewReader(r); err != nil {
+				return nil, fmt.Errorf("invalid gzip tile data: %w", err)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported tile data compression %q", compression)
+		}
+
+		raw, err = io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress tile data: %w", err)
+		}
+		if len(raw)%4 != 0 {
+			return nil, fmt.Errorf("tile data length %v is not a multiple of 4", len(raw))
+		}
+
+		gids := make([]uint32, len(raw)/4)
+		for i := range gids {
+			gids[i] = binary.LittleEndian.Uint32(raw[i*4:])
+		}
+		return gids, nil
+	}
+
+	return nil, fmt.Errorf("unsupported tile data encoding %q", encoding)
+}
+
+func parseFloat(s string, def float64) float64 {
+	if s == "" {
+		return def
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// ---------------------------------------------------------
+// TMX
+
+type tmxProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+	Text  string `xml:",chardata"`
+}
+
+type tmxProperties struct {
+	Properties []tmxProperty `xml:"property"`
+}
+
+func (props tmxProperties) toMap() map[string]string {
+	result := map[string]string{}
+	for _, prop := range props.Properties {
+		value := prop.Value
+		if value == "" {
+			value = prop.Text
+		}
+		result[prop.Name] = value
+	}
+	return result
+}
+
+type tmxTileset struct {
+	FirstGID   int           `xml:"firstgid,attr"`
+	Source     string        `xml:"source,attr"`
+	Name       string        `xml:"name,attr"`
+	TileCount  int           `xml:"tilecount,attr"`
+	Columns    int           `xml:"columns,attr"`
+	Properties tmxProperties `xml:"properties"`
+	Image      struct {
+		Source string `xml:"source,attr"`
+	} `xml:"image"`
+	Tiles []tmxTile `xml:"tile"`
+}
+
+type tmxTile struct {
+	ID         int           `xml:"id,attr"`
+	Properties tmxProperties `xml:"properties"`
+}
+
+type tmxLayer struct {
+	Name       string        `xml:"name,attr"`
+	Visible    string        `xml:"visible,attr"`
+	ParallaxX  string        `xml:"parallaxx,attr"`
+	ParallaxY  string        `xml:"parallaxy,attr"`
+	Properties tmxProperties `xml:"properties"`
+	Data       struct {
+		Encoding    string `xml:"encoding,attr"`
+		Compression string `xml:"compression,attr"`
+		Text        string `xml:",chardata"`
+		Tiles       []struct {
+			GID uint32 `xml:"gid,attr"`
+		} `xml:"tile"`
+		Chunks []struct{} `xml:"chunk"`
+	} `xml:"data"`
+}
+
+type tmxObjectGroup struct {
+	Name       string        `xml:"name,attr"`
+	Visible    string        `xml:"visible,attr"`
+	Properties tmxProperties `xml:"properties"`
+	Objects    []struct {
+		ID         int           `xml:"id,attr"`
+		Name       string        `xml:"name,attr"`
+		Type       string        `xml:"type,attr"`
+		Class      string        `xml:"class,attr"`
+		X          float64       `xml:"x,attr"`
+		Y          float64       `xml:"y,attr"`
+		Width      float64       `xml:"width,attr"`
+		Height     float64       `xml:"height,attr"`
+		GID        uint32        `xml:"gid,attr"`
+		Properties tmxProperties `xml:"properties"`
+		Polyline   *struct {
+			Points string `xml:"points,attr"`
+		} `xml:"polyline"`
+		Polygon *struct {
+			Points string `xml:"points,attr"`
+		} `xml:"polygon"`
+	} `xml:"object"`
+}
+
+type tmxGroup struct {
+	Visible      string           `xml:"visible,attr"`
+	Layers       []tmxLayer       `xml:"layer"`
+	ObjectGroups []tmxObjectGroup `xml:"objectgroup"`
+	Groups       []tmxGroup       `xml:"group"`
+}
+
+type tmxMap struct {
+	Width      int           `xml:"width,attr"`
+	Height     int           `xml:"height,attr"`
+	TileWidth  int           `xml:"tilewidth,attr"`
+	TileHeight int           `xml:"tileheight,attr"`
+	Infinite   int           `xml:"infinite,attr"`
+	Properties tmxProperties `xml:"properties"`
+	Tilesets   []tmxTileset  `xml:"tileset"`
+	tmxGroup
+}
+
+func parseTMX(fsys fs.FS, filename string, data []byte) (*TiledMap, error) {
+	var doc tmxMap
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Infinite != 0 {
+		return nil, fmt.Errorf("infinite maps are not supported")
+	}
+
+	m := &TiledMap{
+		Width:      doc.Width,
+		Height:     doc.Height,
+		TileWidth:  doc.TileWidth,
+		TileHeight: doc.TileHeight,
+		Properties: doc.Properties.toMap(),
+	}
+
+	dir := path.Dir(filename)
+	for _, ts := range doc.Tilesets {
+		firstGID := ts.FirstGID
+		tsDir := dir
+		if ts.Source != "" {
+			tsFilename := path.Join(dir, ts.Source)
+			var err error
+			ts, err = readTSX(fsys, tsFilename)
+			if err != nil {
+				return nil, err
+			}
+			tsDir = path.Dir(tsFilename)
+		}
+
+		tileset := TiledTileset{
+			FirstGID:       firstGID,
+			Name:           ts.Name,
+			Columns:        ts.Columns,
+			TileCount:      ts.TileCount,
+			TileProperties: map[int]map[string]string{},
+		}
+		if ts.Image.Source != "" {
+			tileset.Image = resolveImage(tsDir, ts.Image.Source)
+		}
+		for _, tile := range ts.Tiles {
+			tileset.TileProperties[tile.ID] = tile.Properties.toMap()
+		}
+		m.Tilesets = append(m.Tilesets, tileset)
+	}
+
+	if err := m.addTMXGroup(&doc.tmxGroup, true); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func readTSX(fsys fs.FS, filename string) (tmxTileset, error) {
+	var ts tmxTileset
+	data, err := fs.ReadFile(fsys, filename)
+	if err != nil {
+		return ts, err
+	}
+
+	if path.Ext(filename) == ".tsj" || path.Ext(filename) == ".json" {
+		var doc tmjTileset
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return ts, fmt.Errorf("%v: %w", filename, err)
+		}
+		ts.Name, ts.Columns, ts.TileCount = doc.Name, doc.Columns, doc.TileCount
+		ts.Image.Source = doc.Image
+		for _, tile := range doc.Tiles {
+			props := tmxProperties{}
+			for name, value := range tmjPropertiesMap(tile.Properties) {
+				props.Properties = append(props.Properties, tmxProperty{Name: name, Value: value})
+			}
+			ts.Tiles = append(ts.Tiles, tmxTile{ID: tile.ID, Properties: props})
+		}
+		return ts, nil
+	}
+
+	if err := xml.Unmarshal(data, &ts); err != nil {
+		return ts, fmt.Errorf("%v: %w", filename, err)
+	}
+	return ts, nil
+}
+
+func (m *TiledMap) addTMXGroup(group *tmxGroup, parentVisible bool) error {
+	visible := parentVisible && group.Visible != "0"
+
+	for _, layer := range group.Layers {
+		if len(layer.Data.Chunks) > 0 {
+			return fmt.Errorf("layer %q: chunked tile data is not supported", layer.Name)
+		}
+
+		var gids []uint32
+		if layer.Data.Encoding == "" {
+			for _, tile := range layer.Data.Tiles {
+				gids = append(gids, tile.GID)
+			}
+		} else {
+			var err error
+			gids, err = decodeGIDs(layer.Data.Encoding, layer.Data.Compression, layer.Data.Text)
+			if err != nil {
+				return fmt.Errorf("layer %q: %w", layer.Name, err)
+			}
+		}
+
+		m.TileLayers = append(m.TileLayers, TiledTileLayer{
+			Name:       layer.Name,
+			Visible:    visible && layer.Visible != "0",
+			ParallaxX:  parseFloat(layer.ParallaxX, 1),
+			ParallaxY:  parseFloat(layer.ParallaxY, 1),
+			Properties: layer.Properties.toMap(),
+			GIDs:       gids,
+		})
+	}
+
+	for _, group := range group.ObjectGroups {
+		objectLayer := TiledObjectLayer{
+			Name:       group.Name,
+			Visible:    visible && group.Visible != "0",
+			Properties: group.Properties.toMap(),
+		}
+		for _, obj := range group.Objects {
+			object := TiledObject{
+				ID:         obj.ID,
+				Name:       obj.Name,
+				Type:       obj.Type,
+				X:          obj.X,
+				Y:          obj.Y,
+				Width:      obj.Width,
+				Height:     obj.Height,
+				GID:        obj.GID,
+				Properties: obj.Properties.toMap(),
+			}
+			if object.Type == "" {
+				object.Type = obj.Class
+			}
+
+			points := ""
+			if obj.Polyline != nil {
+				points = obj.Polyline.Points
+			} else if obj.Polygon != nil {
+				points = obj.Polygon.Points
+			}
+			for _, point := range strings.Fields(points) {
+				var p vector.T
+				if _, err := fmt.Sscanf(point, "%g,%g", &p.X, &p.Y); err != nil {
+					return fmt.Errorf("object %v: invalid point %q", obj.ID, point)
+				}
+				object.Points = append(object.Points, p)
+			}
+
+			objectLayer.Objects = append(objectLayer.Objects, object)
+		}
+		m.ObjectLayers = append(m.ObjectLayers, objectLayer)
+	}
+
+	for i := range group.Groups {
+		if err := m.addTMXGroup(&group.Groups[i], visible); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ---------------------------------------------------------
+// TMJ
+
+type tmjProperty struct {
+	Name  string `json:"name"`
+	Value any    `json:"value"`
+}
+
+func tmjPropertiesMap(props []tmjProperty) map[string]string {
+	result := map[string]string{}
+	for _, prop := range props {
+		switch value := prop.Value.(type) {
+		case string:
+			result[prop.Name] = value
+		case nil:
+			result[prop.Name] = ""
+		default:
+			result[prop.Name] = fmt.Sprint(value)
+		}
+	}
+	return result
+}
+
+type tmjTileset struct {
+	FirstGID   int           `json:"firstgid"`
+	Source     string        `json:"source"`
+	Name       string        `json:"name"`
+	Image      string        `json:"image"`
+	TileCount  int           `json:"tilecount"`
+	Columns    int           `json:"columns"`
+	Properties []tmjProperty `json:"properties"`
+	Tiles      []struct {
+		ID         int           `json:"id"`
+		Properties []tmjProperty `json:"properties"`
+	} `json:"tiles"`
+}
+
+type tmjObject struct {
+	ID         int           `json:"id"`
+	Name       string        `json:"name"`
+	Type       string        `json:"type"`
+	Class      string        `json:"class"`
+	X          float64       `json:"x"`
+	Y          float64       `json:"y"`
+	Width      float64       `json:"width"`
+	Height     float64       `json:"height"`
+	GID        uint32        `json:"gid"`
+	Polyline   []vector.T    `json:"polyline"`
+	Polygon    []vector.T    `json:"polygon"`
+	Properties []tmjProperty `json:"properties"`
+}
+
+type tmjLayer struct {
+	Type        string          `json:"type"`
+	Name        string          `json:"name"`
+	Visible     *bool           `json:"visible"`
+	ParallaxX   *float64        `json:"parallaxx"`
+	ParallaxY   *float64        `json:"parallaxy"`
+	Encoding    string          `json:"encoding"`
+	Compression string          `json:"compression"`
+	Data        json.RawMessage `json:"data"`
+	Chunks      json.RawMessage `json:"chunks"`
+	Objects     []tmjObject     `json:"objects"`
+	Layers      []tmjLayer      `json:"layers"`
+	Properties  []tmjProperty   `json:"properties"`
+}
+
+type tmjMap struct {
+	Width      int           `json:"width"`
+	Height     int           `json:"height"`
+	TileWidth  int           `json:"tilewidth"`
+	TileHeight int           `json:"tileheight"`
+	Infinite   bool          `json:"infinite"`
+	Properties []tmjProperty `json:"properties"`
+	Tilesets   []tmjTileset  `json:"tilesets"`
+	Layers     []tmjLayer    `json:"layers"`
+}
+
+func parseTMJ(fsys fs.FS, filename string, data []byte) (*TiledMap, error) {
+	var doc tmjMap
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Infinite {
+		return nil, fmt.Errorf("infinite maps are not supported")
+	}
+
+	m := &TiledMap{
+		Width:      doc.Width,
+		Height:     doc.Height,
+		TileWidth:  doc.TileWidth,
+		TileHeight: doc.TileHeight,
+		Properties: tmjPropertiesMap(doc.Properties),
+	}
+
+	dir := path.Dir(filename)
+	for _, ts := range doc.Tilesets {
+		if ts.Source != "" {
+			tsFilename := path.Join(dir, ts.Source)
+			tsx, err := readTSX(fsys, tsFilename)
+			if err != nil {
+				return nil, err
+			}
+			tileset := TiledTileset{
+				FirstGID:       ts.FirstGID,
+				Name:           tsx.Name,
+				Columns:        tsx.Columns,
+				TileCount:      tsx.TileCount,
+				TileProperties: map[int]map[string]string{},
+			}
+			if tsx.Image.Source != "" {
+				tileset.Image = resolveImage(path.Dir(tsFilename), tsx.Image.Source)
+			}
+			for _, tile := range tsx.Tiles {
+				tileset.TileProperties[tile.ID] = tile.Properties.toMap()
+			}
+			m.Tilesets = append(m.Tilesets, tileset)
+			continue
+		}
+
+		tileset := TiledTileset{
+			FirstGID:       ts.FirstGID,
+			Name:           ts.Name,
+			Columns:        ts.Columns,
+			TileCount:      ts.TileCount,
+			TileProperties: map[int]map[string]string{},
+		}
+		if ts.Image != "" {
+			tileset.Image = resolveImage(dir, ts.Image)
+		}
+		for _, tile := range ts.Tiles {
+			tileset.TileProperties[tile.ID] = tmjPropertiesMap(tile.Properties)
+		}
+		m.Tilesets = append(m.Tilesets, tileset)
+	}
+
+	if err := m.addTMJLayers(doc.Layers, true); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (m *TiledMap) addTMJLayers(layers []tmjLayer, parentVisible bool) error {
+	for _, layer := range layers {
+		visible := parentVisible && (layer.Visible == nil || *layer.Visible)
+
+		switch layer.Type {
+		case "tilelayer":
+			if len(layer.Chunks) > 0 {
+				return fmt.Errorf("layer %q: chunked tile data is not supported", layer.Name)
+			}
+
+			var gids []uint32
+			if layer.Encoding == "base64" {
+				var text string
+				if err := json.Unmarshal(layer.Data, &text); err != nil {
+					return fmt.Errorf("layer %q: %w", layer.Name, err)
+				}
+				var err error
+				gids, err = decodeGIDs(layer.Encoding, layer.Compression, text)
+				if err != nil {
+					return fmt.Errorf("layer %q: %w", layer.Name, err)
+				}
+			} else if err := json.Unmarshal(layer.Data, &gids); err != nil {
+				return fmt.Errorf("layer %q: %w", layer.Name, err)
+			}
+
+			tileLayer := TiledTileLayer{
+				Name:       layer.Name,
+				Visible:    visible,
+				ParallaxX:  1,
+				ParallaxY:  1,
+				Properties: tmjPropertiesMap(layer.Properties),
+				GIDs:       gids,
+			}
+			if layer.ParallaxX != nil {
+				tileLayer.ParallaxX = *layer.ParallaxX
+			}
+			if layer.ParallaxY != nil {
+				tileLayer.ParallaxY = *layer.ParallaxY
+			}
+			m.TileLayers = append(m.TileLayers, tileLayer)
+
+		case "objectgroup":
+			objectLayer := TiledObjectLayer{
+				Name:       layer.Name,
+				Visible:    visible,
+				Properties: tmjPropertiesMap(layer.Properties),
+			}
+			for _, obj := range layer.Objects {
+				object := TiledObject{
+					ID:         obj.ID,
+					Name:       obj.Name,
+					Type:       obj.Type,
+					X:          obj.X,
+					Y:          obj.Y,
+					Width:      obj.Width,
+					Height:     obj.Height,
+					GID:        obj.GID,
+					Points:     obj.Polyline,
+					Properties: tmjPropertiesMap(obj.Properties),
+				}
+				if object.Type == "" {
+					object.Type = obj.Class
+				}
+				if object.Points == nil {
+					object.Points = obj.Polygon
+				}
+				objectLayer.Objects = append(objectLayer.Objects, object)
+			}
+			m.ObjectLayers = append(m.ObjectLayers, objectLayer)
+
+		case "group":
+			if err := m.addTMJLayers(layer.Layers, visible); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+//go:build !nodisplay
+
+package level
+
+import (
+	"os"
+	"testing"
+
+	"github.com/nvlled/dinojump/vector"
+)
+
+// The maps in testdata are the same map saved as .tmx and .tmj, with
+// csv, base64, zlib and gzip tile data, flipped tiles, a group, external
+// tilesets and objects.
+func TestLoadTiled(t *testing.T) {
+	for _, filename := range []string{"map.tmx", "map.tmj"} {
+		t.Run(filename, func(t *testing.T) {
+			level, err := LoadTiled(os.DirFS("testdata"), filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cols, rows := level.Size(); cols != 6 || rows != 4 {
+				t.Fatalf("expected a 6x4 level, got %vx%v", cols, rows)
+			}
+			if len(level.Layers) != 3 {
+				t.Fatalf("expected 3 layers, got %v", len(level.Layers))
+			}
+
+			main := level.GetLayer("main")
+			expectTile(t, main, 0, 0, Tile{TileID: 28, Flags: FlagSolid})
+			expectTile(t, main, 2, 1, Tile{TileID: 12, Flags: FlagOneWay})
+			expectTile(t, main, 2, 3, Tile{TileID: 2, Flags: FlagHazard})
+			expectTile(t, main, 0, 2, Tile{TileID: -1})
+			expectTile(t, main, 1, 2, Tile{TileID: 21, Flags: FlagSolid, Slope: SlopeFloor45R, Flip: 0b10})
+			expectTile(t, main, 4, 2, Tile{TileID: 21, Flags: FlagSolid, Slope: SlopeCeil45R, Flip: 0b100})
+
+			decor := level.GetLayer("decor")
+			if decor.Depth != DepthBackground || decor.Collide || decor.Parallax != vector.Create(0.5, 1) {
+				t.Errorf("decor: got depth %v, collide %v and parallax %v", decor.Depth, decor.Collide, decor.Parallax)
+			}
+			expectTile(t, decor, 0, 2, Tile{TileID: 1})
+
+			front := level.GetLayer("front")
+			if front.Depth != DepthForeground || front.Collide {
+				t.Errorf("front: got depth %v and collide %v", front.Depth, front.Collide)
+			}
+			expectTile(t, front, 5, 1, Tile{TileID: 12, Flags: FlagOneWay})
+
+			if spawn, ok := level.SpawnPoint(); !ok || spawn != vector.Create(75, 125) {
+				t.Errorf("expected the spawn at (75, 125), got %v", spawn)
+			}
+			if goal := level.FindObject(ObjectGoal); goal == nil || goal.Prop("next", "") != "level2" {
+				t.Errorf("expected a goal with a next property, got %+v", goal)
+			}
+
+			if len(level.Platforms) != 1 {
+				t.Fatalf("expected 1 platform, got %v", len(level.Platforms))
+			}
+			lift := level.Platforms[0]
+			path := []vector.T{vector.Create(100, 100), vector.Create(200, 100)}
+			if lift.Name != "lift" || lift.Tile.Flags != FlagOneWay || lift.Speed != 1.5 ||
+				lift.Rect.Width() != 100 || len(lift.Path) != 2 || lift.Path[0] != path[0] || lift.Path[1] != path[1] {
+				t.Errorf("unexpected platform %+v", lift)
+			}
+		})
+	}
+}
+
+func TestTiledFlipSlope(t *testing.T) {
+	m := &TiledMap{
+		Width:  2,
+		Height: 1,
+		Tilesets: []TiledTileset{{
+			FirstGID:  1,
+			Name:      "lemcraft",
+			Image:     "lemcraft-tiles.png",
+			Columns:   7,
+			TileCount: 56,
+			TileProperties: map[int]map[string]string{
+				0: {"slope": "floor45l"},
+				1: {"slope": "floor22r1"},
+			},
+		}},
+	}
+
+	cases := []struct {
+		gid   uint32
+		slope Slope
+	}{
+		{1, SlopeFloor45L},
+		{1 | tiledFlipH, SlopeFloor45R},
+		{1 | tiledFlipV, SlopeCeil45L},
+		{1 | tiledFlipD, SlopeCeil45R},
+		// Rotated 90° clockwise in Tiled.
+		{1 | tiledFlipD | tiledFlipH, SlopeCeil45L},
+		{2 | tiledFlipH | tiledFlipV, SlopeCeil22L1},
+	}
+	for _, c := range cases {
+		tile, err := m.tile(c.gid, &m.Tilesets[0])
+		if err != nil {
+			t.Errorf("GID %#x: %v", c.gid, err)
+			continue
+		}
+		if tile.Slope != c.slope {
+			t.Errorf("GID %#x: expected %v, got %v", c.gid, c.slope, tile.Slope)
+		}
+	}
+
+	if _, err := m.tile(2|tiledFlipD, &m.Tilesets[0]); err == nil {
+		t.Errorf("expected an error for a diagonally flipped 22.5° slope")
+	}
+}
+
+func expectTile(t *testing.T, layer *Layer, c, r int, expected Tile) {
+	t.Helper()
+	if tile, _ := layer.GetTileAt(c, r); tile != expected {
+		t.Errorf("%v: expected %+v on column %v and row %v, got %+v", layer.Name, expected, c, r, tile)
+	}
+}
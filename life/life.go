@@ -0,0 +1,49 @@
+package life
+
+import (
+	"github.com/nvlled/dinojump/level"
+	"github.com/nvlled/dinojump/rect"
+	"github.com/nvlled/dinojump/vector"
+)
+
+// T keeps track of where the dino respawns and how many times it died.
+type T struct {
+	// Checkpoint is where the dino respawns, starting
+	// from where it was on the first update.
+	Checkpoint vector.T
+	Deaths     int
+
+	started bool
+}
+
+// Update moves the checkpoint to the checkpoint objects that r touches.
+func (life *T) Update(lvl *level.T, pos vector.T, r *rect.T) {
+	if !life.started {
+		life.Checkpoint = pos
+		life.started = true
+	}
+	for _, obj := range lvl.ObjectsIn(r) {
+		if obj.Type != level.ObjectCheckpoint {
+			continue
+		}
+		life.Checkpoint = obj.Rect.Mid()
+	}
+}
+
+// Restore sets the checkpoint and deaths, like from a saved game.
+func (life *T) Restore(checkpoint vector.T, deaths int) {
+	life.Checkpoint = checkpoint
+	life.Deaths = deaths
+	life.started = true
+}
+
+func (life *T) Die() {
+	life.Deaths++
+}
+
+// OutOfWorld returns true if r fell below the level,
+// or if it's completely outside of the sides.
+func OutOfWorld(lvl *level.T, r *rect.T) bool {
+	world := lvl.GetRect()
+	return r.Top() > world.Bottom() || r.Right() < world.Left() || r.Left() > world.Right()
+}
@@ -0,0 +1,84 @@
+//go:build !nodisplay
+
+package life
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/nvlled/dinojump/level"
+	"github.com/nvlled/dinojump/rect"
+	"github.com/nvlled/dinojump/vector"
+)
+
+func loadLevel(t *testing.T) *level.T {
+	t.Helper()
+	fsys := fstest.MapFS{"life.level": {Data: []byte(`
+atlas: lemcraft-tiles.png
+tilesize: 50
+tile: # 14
+tile: . 30 0
+object: checkpoint 3,1 1x2
+---
+......
+......
+######
+`)}}
+	lvl, err := level.LoadFile(fsys, "life.level")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lvl
+}
+
+func TestCheckpoint(t *testing.T) {
+	lvl := loadLevel(t)
+	var life T
+
+	start := vector.Create(37.5, 87.5)
+	r := rect.Create(25, 75, 25, 25)
+	life.Update(lvl, start, &r)
+	if life.Checkpoint != start {
+		t.Errorf("expected the checkpoint to start at %v, got %v", start, life.Checkpoint)
+	}
+
+	r = rect.Create(140, 75, 25, 25)
+	life.Update(lvl, r.Mid(), &r)
+	if expected := vector.Create(175, 100); life.Checkpoint != expected {
+		t.Errorf("expected the checkpoint at the middle of the object %v, got %v", expected, life.Checkpoint)
+	}
+
+	// Going past the checkpoint keeps it.
+	r = rect.Create(250, 75, 25, 25)
+	life.Update(lvl, r.Mid(), &r)
+	if expected := vector.Create(175, 100); life.Checkpoint != expected {
+		t.Errorf("expected the checkpoint to stay at %v, got %v", expected, life.Checkpoint)
+	}
+
+	life.Die()
+	life.Die()
+	if life.Deaths != 2 {
+		t.Errorf("expected 2 deaths, got %v", life.Deaths)
+	}
+}
+
+func TestOutOfWorld(t *testing.T) {
+	lvl := loadLevel(t)
+	cases := []struct {
+		name string
+		rect rect.T
+		out  bool
+	}{
+		{"inside", rect.Create(100, 75, 25, 25), false},
+		{"above", rect.Create(100, -100, 25, 25), false},
+		{"partly outside of the left", rect.Create(-10, 75, 25, 25), false},
+		{"below", rect.Create(100, 151, 25, 25), true},
+		{"left", rect.Create(-26, 75, 25, 25), true},
+		{"right", rect.Create(301, 75, 25, 25), true},
+	}
+	for _, c := range cases {
+		if out := OutOfWorld(lvl, &c.rect); out != c.out {
+			t.Errorf("%v: expected out of world to be %v", c.name, c.out)
+		}
+	}
+}
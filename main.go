@@ -1,41 +1,54 @@
 package main
 
 import (
-	// ---------------------------------------------------------
-	// Uncomment only one of the following:
-	dino "github.com/nvlled/dinojump/dino_coroutine"
-	//dino "github.com/nvlled/dinojump/dino_enums"
-	//dino "github.com/nvlled/dinojump/dino_func"
-	// ---------------------------------------------------------
-
+	"flag"
 	"image/color"
 	"log"
 	"math"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/nvlled/dinojump/action"
 	"github.com/nvlled/dinojump/common"
+	"github.com/nvlled/dinojump/dinos"
+	"github.com/nvlled/dinojump/input"
 
 	"github.com/nvlled/dinojump/level"
 	"github.com/nvlled/dinojump/rect"
+	"github.com/nvlled/dinojump/scene"
 	"github.com/nvlled/dinojump/scrdbg"
+	"github.com/nvlled/dinojump/tuning"
 	"github.com/nvlled/dinojump/vector"
 
 	_ "image/jpeg"
 )
 
-var renderTileSize = 50
-
 var Debug = false
 
+var dinoFlag = flag.String("dino", "coroutine", "dino implementation: "+strings.Join(dinos.Names(), ", "))
+var levelFlag = flag.String("level", "", "level file to play instead of the levels of the manifest, from the disk or from the embedded assets")
+var manifestFlag = flag.String("levels", "levels/levels.txt", "manifest of the levels to play in order")
+var replayFlag = flag.String("replay", "", "play back a replay file")
+var headlessFlag = flag.Bool("headless", false, "play back the replay without opening a window")
+var tuningFlag = flag.String("tuning", "assets/tuning.txt", "physics parameters of the dino, reloaded when the file changes")
+var bindingsFlag = flag.String("bindings", "assets/bindings.txt", "keys and gamepad inputs bound to the actions of the dino")
+var tpsFlag = flag.Int("tps", ebiten.SyncWithFPS, "how many times per second ebiten updates, -1 to sync with the frame rate. The game itself always runs at 60 ticks per second")
+
+var defaultSpawn = vector.Create(200, 200)
+
 var initialized sync.Once
 
+// tuningChanged is signaled by handleFileChange, so that
+// the tuning is reloaded between updates.
+var tuningChanged = make(chan struct{}, 1)
+
 type Game struct {
 	viewSize  vector.T
 	worldSize vector.T
@@ -43,7 +56,14 @@ type Game struct {
 	viewRect  rect.T
 	worldRect rect.T
 
-	dino *dino.Sprite
+	dino      dinos.Dino
+	dinoIndex int
+
+	input      input.Frame
+	recording  *input.Replay
+	replay     *input.Replay
+	replayTick int
+	lastReplay *input.Replay
 
 	canvas *ebiten.Image
 
@@ -54,101 +74,192 @@ type Game struct {
 
 	level *level.T
 
+	// levels are the filenames of the levels played in order.
+	levels     []string
+	levelIndex int
+
+	scenes *scene.Stack
+	rewind Rewind
+	clock  Clock
+
 	renderTileSize int
 
 	UpdateActions action.ActionSet[common.Void]
 	DrawActions   action.ActionSet[*ebiten.Image]
 }
 
-func createLevel(renderTileSize int) *level.T {
-	return level.NewLevel(level.NewOptions{
-		RenderTileSize:     renderTileSize,
-		AtlasFilename:      "lemcraft-tiles.png",
-		BackgroundFilename: "Cielo pixelado.png",
-		TileMap: map[rune]level.Tile{
-			'v': level.CreateTile(28),
-			'^': level.CreateTile(14),
-			'*': level.CreateTile(12),
-			'|': level.CreateTile(11),
-		},
-	}, `
-|vvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvvv|
-|      ****                                                      |
-|   **                                                           |
-|     **************************                                 |
-| *                                  ********                    |
-| **  *   *****                      *                           |
-| *                  *  **                                       |
-| **  * * * *  *******    *                                      |
-| *           *                                                  |
-|                                                                |
-|^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^^|
-`)
-}
-
-func NewGame() *Game {
-	level := createLevel(renderTileSize)
-
-	levelW, levelH := level.TotalSize()
-	worldW, worldH := float64(levelW), float64(levelH)
-
+func NewGame(dinoIndex int, level *level.T) *Game {
 	var viewW, viewH float64 = 500, 400
 
 	game := &Game{
-		renderTileSize: renderTileSize,
-
-		viewSize:  vector.Create(viewW, viewH),
-		worldSize: vector.Create(worldW, worldH),
-		worldRect: rect.Create(0, 0, worldW, worldH),
-		viewRect:  rect.Create(0, 0, viewW, viewH),
-
-		canvas: ebiten.NewImage(int(worldW), int(worldH)),
+		viewSize: vector.Create(viewW, viewH),
+		viewRect: rect.Create(0, 0, viewW, viewH),
 
 		UpdateActions: *action.NewSet[common.Void](),
 		DrawActions:   *action.NewSet[*ebiten.Image](),
-
-		level: level,
-
-		camera: NewCamera(
-			viewW/2, viewH/2,
-			viewW, viewH,
-			0.6,
-		),
 	}
+	game.setWorld(level)
 
-	game.dino = dino.New(level)
+	game.dinoIndex = dinoIndex
+	game.dino = dinos.All[dinoIndex].New(level)
+	game.dino.SetInput(&game.input)
 
 	return game
 }
 
+// setWorld makes the level the current one, resizing
+// the canvas and the camera bounds to it.
+func (g *Game) setWorld(level *level.T) {
+	levelW, levelH := level.TotalSize()
+	worldW, worldH := float64(levelW), float64(levelH)
+	viewW, viewH := g.viewSize.XY()
+
+	g.level = level
+	g.renderTileSize = level.RenderTileSize
+	g.worldSize = vector.Create(worldW, worldH)
+	g.worldRect = rect.Create(0, 0, worldW, worldH)
+
+	if g.canvas != nil {
+		g.canvas.Dispose()
+	}
+	g.canvas = ebiten.NewImage(int(worldW), int(worldH))
+
+	g.camera = NewCamera(
+		viewW/2, viewH/2,
+		viewW, viewH,
+		0.6,
+	)
+}
+
 func (g *Game) Initialize() {
-	dino := g.dino
+	dino := g.dino.GetSprite()
 	viewW, viewH := g.viewSize.XY()
 
-	dino.Pos = vector.Create(200, 200)
-	dino.DrawSize.X = float64(g.renderTileSize / 2)
-	dino.DrawSize.Y = float64(g.renderTileSize / 2)
-	dino.CollisionScale = vector.Create(1.9, 1.9)
+	dino.Pos = g.spawnPoint()
+	dinos.Configure(g.dino, g.renderTileSize)
 
 	scrdbg.Default.Screen = ebiten.NewImage(int(viewW), int(viewH))
+
+	if g.lastReplay != nil {
+		g.StartReplay(g.lastReplay)
+	}
+}
+
+// SwapDino replaces the current dino with another implementation,
+// keeping its position and velocity.
+func (g *Game) SwapDino(index int) {
+	old := g.dino.GetSprite()
+	dino := g.newDino(index)
+
+	sprite := dino.GetSprite()
+	sprite.Pos = old.Pos
+	sprite.Vel = old.Vel
+	sprite.Flip = old.Flip
+	*dino.GetLife() = *g.dino.GetLife()
+
+	g.dino = dino
+	g.dinoIndex = index
+	println("dino:", dinos.All[index].Name)
+}
+
+func (g *Game) spawnPoint() vector.T {
+	if spawn, ok := g.level.SpawnPoint(); ok {
+		return spawn
+	}
+	return defaultSpawn
+}
+
+func (g *Game) newDino(index int) dinos.Dino {
+	dino := dinos.All[index].New(g.level)
+	dino.SetInput(&g.input)
+	dinos.Configure(dino, g.renderTileSize)
+	return dino
 }
 
 func (g *Game) Update() error {
 	g.startTime = time.Now()
-	g.dino.Update()
-	initialized.Do(g.Initialize)
+	if g.checkGoal() || g.updateRewind() {
+		g.endTime = time.Now()
+		return nil
+	}
+	g.updateClock()
+	g.updateTuning()
+	for n := g.clock.Advance(g.startTime); n > 0; n-- {
+		g.tick()
+	}
 
-	g.camera.Follow(&g.dino.Pos)
-	rect.Layout.Restrict(&g.camera.Rect, &g.worldRect)
+	if inpututil.IsKeyJustPressed(ebiten.KeyF2) && g.recording == nil && g.replay == nil {
+		g.SwapDino((g.dinoIndex + 1) % len(dinos.All))
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyF3) {
+		g.SaveSnapshot(quicksaveFile)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyF4) {
+		g.LoadSnapshot(quicksaveFile)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyF5) {
+		g.ToggleRecording()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyF6) && g.lastReplay != nil {
+		g.StartReplay(g.lastReplay)
+	}
+
+	scrdbg.Reset()
+	scrdbg.Printf("dino: %v (F2)", dinos.All[g.dinoIndex].Name)
+	scrdbg.Printf("level: %v (%v/%v)", g.level.Name, g.levelIndex+1, len(g.levels))
+	scrdbg.Printf("deaths: %v", g.dino.GetLife().Deaths)
+	if g.recording != nil {
+		scrdbg.Printf("recording: %v ticks (F5 to stop)", len(g.recording.States))
+	} else if g.replay != nil {
+		scrdbg.Printf("replaying: %v/%v", g.replayTick, len(g.replay.States))
+	}
+	scrdbg.Printf("time: %v", g.clock)
 
-	if ebiten.IsKeyPressed(ebiten.KeyEscape) {
-		os.Exit(0)
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.scenes.Push(newPauseScene(g))
 	}
 	g.endTime = time.Now()
 
 	return nil
 }
 
+// updateTuning reloads the tuning file after it changed,
+// keeping the current tuning if the file has errors.
+// Replays don't record the tuning, so a change is only
+// loaded after the recording or replay ends.
+func (g *Game) updateTuning() {
+	if g.recording != nil || g.replay != nil {
+		return
+	}
+	select {
+	case <-tuningChanged:
+	default:
+		return
+	}
+	t, err := tuning.Load(*tuningFlag)
+	if err != nil {
+		println("failed to reload tuning:", err.Error())
+		return
+	}
+	tuning.Current = t
+	println("tuning reloaded")
+}
+
+// tick advances the game by a single tick.
+func (g *Game) tick() {
+	g.updateInput()
+	g.level.Update()
+	g.dino.Update()
+	initialized.Do(g.Initialize)
+
+	g.camera.Follow(&g.dino.GetSprite().Pos)
+	rect.Layout.Restrict(&g.camera.Rect, &g.worldRect)
+
+	if g.recording == nil && g.replay == nil {
+		g.rewind.Push(g.TakeSnapshot())
+	}
+}
+
 func (g *Game) QueueDraw(fn func(*ebiten.Image)) {
 	g.DrawActions.Add(fn)
 	g.DrawActions.ClearNextApply()
@@ -159,10 +270,16 @@ var maxDuration float64
 func (g *Game) Draw(screen *ebiten.Image) {
 	g.canvas.Fill(color.RGBA{32, 82, 82, 0xff})
 
-	g.level.Draw(g.canvas, &g.camera.Rect)
+	lag := g.clock.Lag()
+	view := g.camera.View(lag)
+	g.level.DrawLag = lag
+	g.dino.GetSprite().DrawLag = lag
+
+	g.level.Draw(g.canvas, &view)
 	g.dino.Draw(g.canvas)
+	g.level.DrawForeground(g.canvas, &view)
 
-	subCanvas := g.camera.Render(g.canvas)
+	subCanvas := g.camera.Render(g.canvas, &view)
 	screen.DrawImage(subCanvas, &ebiten.DrawImageOptions{})
 	screen.DrawImage(scrdbg.Default.Screen, &ebiten.DrawImageOptions{})
 
@@ -184,25 +301,86 @@ func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeigh
 }
 
 func main() {
+	flag.Parse()
+	dinoIndex := dinos.IndexOf(*dinoFlag)
+	if dinoIndex < 0 {
+		log.Fatalf("unknown dino implementation %q, must be one of: %v", *dinoFlag, strings.Join(dinos.Names(), ", "))
+	}
+
+	var replay *input.Replay
+	if *replayFlag != "" {
+		var err error
+		replay, err = input.LoadReplay(*replayFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	levels := []string{*levelFlag}
+	if replay != nil && replay.Level != "" {
+		levels = []string{replay.Level}
+	} else if *levelFlag == "" {
+		var err error
+		levels, err = level.ReadManifest(*manifestFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	level, err := level.Load(levels[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tuning.Current, err = tuning.Load(*tuningFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	input.CurrentBindings, err = input.LoadBindings(*bindingsFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *headlessFlag {
+		if replay == nil {
+			log.Fatal("-headless requires a -replay file")
+		}
+		if err := runHeadless(level, replay); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
 	ebiten.SetWindowSize(1500, 1000)
 	ebiten.SetFullscreen(false)
-	ebiten.SetTPS(60)
+	ebiten.SetTPS(*tpsFlag)
+
+	go handleFileChange(os.Getenv("EXIT_ON_MODIFY") == "1")
+
+	game := NewGame(dinoIndex, level)
+	game.levels = levels
+	game.lastReplay = replay
 
-	if os.Getenv("EXIT_ON_MODIFY") == "1" {
-		go handleFileChange()
+	var first scene.Scene = game
+	if replay == nil {
+		first = newTitleScene(game)
 	}
+	game.scenes = scene.New(first)
 
-	if err := ebiten.RunGame(NewGame()); err != nil {
+	if err := ebiten.RunGame(game.scenes); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func handleFileChange() {
+// handleFileChange reloads the tuning when its file changes, and
+// exits when a .go file changes if exitOnModify is true.
+func handleFileChange(exitOnModify bool) {
 	w, err := fsnotify.NewWatcher()
 	common.RuhOh(err)
 
 	w.Add(".")
+	w.Add(filepath.Dir(*tuningFlag))
 
 	files, err := os.ReadDir(".")
 	common.RuhOh(err)
@@ -214,10 +392,15 @@ func handleFileChange() {
 	}
 
 	for e := range w.Events {
-		if filepath.Ext(e.Name) != ".go" {
+		modified := e.Op&(fsnotify.Write|fsnotify.Create) != 0
+		if modified && filepath.Clean(e.Name) == filepath.Clean(*tuningFlag) {
+			select {
+			case tuningChanged <- struct{}{}:
+			default:
+			}
 			continue
 		}
-		if e.Op == fsnotify.Write || e.Op == fsnotify.Create {
+		if exitOnModify && modified && filepath.Ext(e.Name) == ".go" {
 			os.Exit(0)
 		}
 	}
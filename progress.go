@@ -0,0 +1,75 @@
+package main
+
+import (
+	"github.com/nvlled/dinojump/level"
+)
+
+// checkGoal shows the results when the dino reaches a goal,
+// and returns true if it did.
+func (g *Game) checkGoal() bool {
+	if g.replay != nil || !g.reachedGoal() {
+		return false
+	}
+	if g.recording != nil {
+		g.ToggleRecording()
+	}
+	println("level complete")
+	g.scenes.Push(&resultsScene{game: g})
+	return true
+}
+
+func (g *Game) reachedGoal() bool {
+	if g.dino.StateName() == "dead" {
+		return false
+	}
+	r := g.dino.GetSprite().Rect
+	for _, obj := range g.level.ObjectsIn(&r) {
+		if obj.Type == level.ObjectGoal {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadLevel makes the level at index of the level sequence
+// the current one, and respawns the dino there.
+func (g *Game) LoadLevel(index int) error {
+	lvl, err := level.Load(g.levels[index])
+	if err != nil {
+		return err
+	}
+
+	deaths := g.dino.GetLife().Deaths
+	g.levelIndex = index
+	g.setWorld(lvl)
+	g.ResetDino(g.dinoIndex)
+	g.dino.GetLife().Deaths = deaths
+
+	println("level:", lvl.Name)
+	return nil
+}
+
+// NextLevel loads the next level, or the first one after the last.
+func (g *Game) NextLevel() {
+	next := (g.levelIndex + 1) % len(g.levels)
+	if err := g.LoadLevel(next); err != nil {
+		println("failed to load level:", err.Error())
+	}
+}
+
+func (g *Game) RestartLevel() {
+	g.replay = nil
+	if g.recording != nil {
+		g.ToggleRecording()
+	}
+	if err := g.LoadLevel(g.levelIndex); err != nil {
+		println("failed to load level:", err.Error())
+	}
+}
+
+// Restart goes back to the first level, with no deaths.
+func (g *Game) Restart() {
+	g.levelIndex = 0
+	g.RestartLevel()
+	g.dino.GetLife().Deaths = 0
+}
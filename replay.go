@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nvlled/dinojump/dinos"
+	"github.com/nvlled/dinojump/input"
+	"github.com/nvlled/dinojump/level"
+	"github.com/nvlled/dinojump/sim"
+)
+
+// updateInput feeds the input state of the current tick to the dino,
+// either from the keyboard or from the replay that is playing.
+func (g *Game) updateInput() {
+	var state input.State
+	if g.replay != nil && g.replayTick < len(g.replay.States) {
+		state = g.replay.States[g.replayTick]
+		g.replayTick++
+	} else {
+		if g.replay != nil {
+			println("replay ended")
+			g.replay = nil
+		}
+		state = input.Poll()
+	}
+
+	if g.recording != nil {
+		g.recording.States = append(g.recording.States, state)
+	}
+
+	g.input.Push(state)
+}
+
+// ResetDino replaces the dino with a freshly spawned one,
+// so that recordings and replays start from the same state.
+func (g *Game) ResetDino(index int) {
+	dino := g.newDino(index)
+	dino.GetSprite().Pos = g.spawnPoint()
+	g.level.Reset()
+
+	g.dino = dino
+	g.dinoIndex = index
+	g.input = input.Frame{}
+	g.rewind.Clear()
+}
+
+func (g *Game) ToggleRecording() {
+	if g.recording == nil {
+		g.replay = nil
+		g.ResetDino(g.dinoIndex)
+		g.recording = &input.Replay{
+			Dino:  dinos.All[g.dinoIndex].Name,
+			Level: g.level.Name,
+			Spawn: g.spawnPoint(),
+		}
+		println("recording started")
+		return
+	}
+
+	recording := g.recording
+	g.recording = nil
+	g.lastReplay = recording
+
+	filename := time.Now().Format("20060102-150405") + ".replay"
+	if err := input.SaveReplay(filename, recording); err != nil {
+		println("failed to save replay:", err.Error())
+		return
+	}
+	println("recording saved to", filename)
+}
+
+func (g *Game) StartReplay(replay *input.Replay) {
+	index := dinos.IndexOf(replay.Dino)
+	if index < 0 {
+		println("replay has unknown dino implementation:", replay.Dino)
+		return
+	}
+
+	if replay.Level != "" {
+		levelIndex := g.indexOfLevel(replay.Level)
+		if levelIndex < 0 {
+			println("replay has a level that isn't played:", replay.Level)
+			return
+		}
+		lvl, err := level.Load(replay.Level)
+		if err != nil {
+			println("failed to load level:", err.Error())
+			return
+		}
+		g.levelIndex = levelIndex
+		g.setWorld(lvl)
+	}
+
+	g.recording = nil
+	g.ResetDino(index)
+	g.dino.GetSprite().Pos = replay.Spawn
+	g.replay = replay
+	g.replayTick = 0
+	println("replay started")
+}
+
+// indexOfLevel returns the index of the level filename
+// in the level sequence, or -1 if it's not there.
+func (g *Game) indexOfLevel(filename string) int {
+	for i, name := range g.levels {
+		if name == filename {
+			return i
+		}
+	}
+	return -1
+}
+
+func runHeadless(level *level.T, replay *input.Replay) error {
+	world, err := sim.NewReplayWorld(level, replay)
+	if err != nil {
+		return err
+	}
+
+	world.RunScript(nil)
+
+	sample := sim.TakeSample(world)
+	fmt.Printf(
+		"tick=%v state=%v pos=(%v, %v) vel=(%v, %v) tile=%v\n",
+		sample.Tick, sample.State,
+		sample.Pos.X, sample.Pos.Y,
+		sample.Vel.X, sample.Vel.Y,
+		sample.TileID,
+	)
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/nvlled/dinojump/dinos"
+	"github.com/nvlled/dinojump/input"
+	"github.com/nvlled/dinojump/scrdbg"
+	"github.com/nvlled/dinojump/snapshot"
+)
+
+// rewindTicks is how many of the last ticks can be rewound.
+const rewindTicks = 10 * 60
+
+// Rewind is a ring buffer of the snapshots of the last ticks.
+// While rewinding, the cursor is the tick that is shown.
+type Rewind struct {
+	frames []*snapshot.T
+	start  int
+	count  int
+
+	active bool
+	cursor int
+}
+
+func (rewind *Rewind) Len() int {
+	return rewind.count
+}
+
+// At returns the i-th oldest snapshot.
+func (rewind *Rewind) At(i int) *snapshot.T {
+	return rewind.frames[(rewind.start+i)%len(rewind.frames)]
+}
+
+func (rewind *Rewind) Push(snap *snapshot.T) {
+	if rewind.frames == nil {
+		rewind.frames = make([]*snapshot.T, rewindTicks)
+	}
+	if rewind.count < len(rewind.frames) {
+		rewind.frames[(rewind.start+rewind.count)%len(rewind.frames)] = snap
+		rewind.count++
+		return
+	}
+	rewind.frames[rewind.start] = snap
+	rewind.start = (rewind.start + 1) % len(rewind.frames)
+}
+
+// Truncate drops the snapshots after the n oldest ones.
+func (rewind *Rewind) Truncate(n int) {
+	if n < rewind.count {
+		rewind.count = n
+	}
+}
+
+func (rewind *Rewind) Clear() {
+	rewind.start = 0
+	rewind.count = 0
+	rewind.active = false
+}
+
+// updateRewind walks the game back while backspace is held.
+// Once rewinding, [ and ] step one tick backward and forward,
+// and pressing any of the game keys resumes from the shown tick.
+// It returns true while the game is rewinding.
+func (g *Game) updateRewind() bool {
+	r := &g.rewind
+	if g.recording != nil || g.replay != nil || r.Len() == 0 {
+		return false
+	}
+	if !r.active {
+		if !ebiten.IsKeyPressed(ebiten.KeyBackspace) {
+			return false
+		}
+		r.active = true
+		r.cursor = r.Len() - 1
+		println("rewind")
+	}
+
+	// Holding backspace goes back a tick per game tick,
+	// so it rewinds at the speed the game was played.
+	ticks := g.clock.Advance(g.startTime)
+	if ebiten.IsKeyPressed(ebiten.KeyBackspace) {
+		r.cursor -= ticks
+		if r.cursor < 0 {
+			r.cursor = 0
+		}
+	} else if inpututil.IsKeyJustPressed(ebiten.KeyBracketLeft) {
+		if r.cursor > 0 {
+			r.cursor--
+		}
+	} else if inpututil.IsKeyJustPressed(ebiten.KeyBracketRight) {
+		if r.cursor < r.Len()-1 {
+			r.cursor++
+		}
+	} else if input.Poll() != 0 {
+		snap := r.At(r.cursor)
+		r.Truncate(r.cursor + 1)
+		r.active = false
+		g.restoreState(snap, dinos.IndexOf(snap.Impl))
+		println("resume")
+		return false
+	}
+
+	g.showFrame(r.At(r.cursor))
+
+	scrdbg.Reset()
+	scrdbg.Printf("rewind: %v/%v (backspace, [ and ] to scrub)", r.cursor+1, r.Len())
+	scrdbg.Printf("state: %v", r.At(r.cursor).Dino.State)
+	scrdbg.Printf("press arrow keys or space to resume")
+	return true
+}
+
+// showFrame moves the dino, camera and platforms to where they
+// were in the snapshot, without restoring the rest of the state.
+func (g *Game) showFrame(snap *snapshot.T) {
+	g.level.SetPlatformStates(snap.Platforms)
+	g.camera.Rect = snap.Camera
+	g.camera.InnerRect = snap.CameraInner
+	g.camera.PrevRect = snap.Camera
+
+	sprite := g.dino.GetSprite()
+	sprite.Pos = snap.Dino.Pos
+	sprite.PrevPos = sprite.Pos
+	sprite.Rect.SetMidXY(sprite.Pos.XY())
+	sprite.Flip = snap.Dino.Flip
+	sprite.Rotation = snap.Dino.Rotation
+	sprite.CurrentTileID = snap.Dino.TileID
+}
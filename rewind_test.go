@@ -0,0 +1,66 @@
+//go:build !nodisplay
+
+package main
+
+import (
+	"testing"
+
+	"github.com/nvlled/dinojump/snapshot"
+)
+
+// pushTicks pushes snapshots numbered by their tick in Turns.
+func pushTicks(rewind *Rewind, from, to int) {
+	for i := from; i < to; i++ {
+		rewind.Push(&snapshot.T{Dino: snapshot.Dino{Turns: i}})
+	}
+}
+
+func expectTicks(t *testing.T, rewind *Rewind, first, last int) {
+	t.Helper()
+	if n := last - first + 1; rewind.Len() != n {
+		t.Fatalf("expected %v snapshots, got %v", n, rewind.Len())
+	}
+	for i := 0; i < rewind.Len(); i++ {
+		if tick := rewind.At(i).Dino.Turns; tick != first+i {
+			t.Fatalf("expected tick %v at %v, got %v", first+i, i, tick)
+		}
+	}
+}
+
+func TestRewindWraparound(t *testing.T) {
+	var rewind Rewind
+	pushTicks(&rewind, 0, 100)
+	expectTicks(t, &rewind, 0, 99)
+
+	// Only the last rewindTicks are kept.
+	pushTicks(&rewind, 100, rewindTicks+250)
+	expectTicks(t, &rewind, 250, rewindTicks+249)
+}
+
+func TestRewindTruncate(t *testing.T) {
+	var rewind Rewind
+	pushTicks(&rewind, 0, rewindTicks+250)
+
+	// Resuming from the 100th oldest snapshot drops the ones after
+	// it, and the new ticks continue from there.
+	rewind.Truncate(100)
+	expectTicks(t, &rewind, 250, 349)
+	pushTicks(&rewind, 350, 400)
+	expectTicks(t, &rewind, 250, 399)
+
+	// Going past the end again wraps around.
+	pushTicks(&rewind, 400, 400+rewindTicks)
+	expectTicks(t, &rewind, 400, 399+rewindTicks)
+
+	rewind.Truncate(rewindTicks + 10)
+	if rewind.Len() != rewindTicks {
+		t.Errorf("expected truncating past the end to keep %v snapshots, got %v", rewindTicks, rewind.Len())
+	}
+
+	rewind.Clear()
+	if rewind.Len() != 0 || rewind.active {
+		t.Errorf("expected no snapshots after clearing, got %v", rewind.Len())
+	}
+	pushTicks(&rewind, 0, 10)
+	expectTicks(t, &rewind, 0, 9)
+}
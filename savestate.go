@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/nvlled/dinojump/dinos"
+	"github.com/nvlled/dinojump/level"
+	"github.com/nvlled/dinojump/snapshot"
+)
+
+// quicksaveFile is where F3 saves the game and F4 loads it from.
+const quicksaveFile = "quicksave.snapshot"
+
+func (g *Game) TakeSnapshot() *snapshot.T {
+	return &snapshot.T{
+		Version:     snapshot.Version,
+		Impl:        dinos.All[g.dinoIndex].Name,
+		Level:       g.levels[g.levelIndex],
+		LevelIndex:  g.levelIndex,
+		Camera:      g.camera.Rect,
+		CameraInner: g.camera.InnerRect,
+		Dino:        g.dino.Save(),
+		Platforms:   g.level.PlatformStates(),
+	}
+}
+
+// RestoreSnapshot reloads the level of the snapshot,
+// and replaces the dino with one in the saved state.
+func (g *Game) RestoreSnapshot(snap *snapshot.T) error {
+	index := dinos.IndexOf(snap.Impl)
+	if index < 0 {
+		return fmt.Errorf("unknown dino implementation %q", snap.Impl)
+	}
+	lvl, err := level.Load(snap.Level)
+	if err != nil {
+		return err
+	}
+	if !lvl.SetPlatformStates(snap.Platforms) {
+		return fmt.Errorf("the level %v has %v platforms, the snapshot has %v",
+			snap.Level, len(lvl.Platforms), len(snap.Platforms))
+	}
+
+	g.recording = nil
+	g.replay = nil
+
+	if snap.LevelIndex >= 0 && snap.LevelIndex < len(g.levels) && g.levels[snap.LevelIndex] == snap.Level {
+		g.levelIndex = snap.LevelIndex
+	} else {
+		g.levels = []string{snap.Level}
+		g.levelIndex = 0
+	}
+	g.setWorld(lvl)
+	g.rewind.Clear()
+	g.restoreState(snap, index)
+	return nil
+}
+
+// restoreState restores the snapshot in the current level, replacing
+// the dino with the implementation at index.
+func (g *Game) restoreState(snap *snapshot.T, index int) {
+	g.level.SetPlatformStates(snap.Platforms)
+	g.camera.Rect = snap.Camera
+	g.camera.InnerRect = snap.CameraInner
+	g.camera.PrevRect = snap.Camera
+
+	g.dino = g.newDino(index)
+	g.dinoIndex = index
+	g.dino.Restore(&snap.Dino)
+
+	sprite := g.dino.GetSprite()
+	sprite.PrevPos = sprite.Pos
+}
+
+func (g *Game) SaveSnapshot(filename string) {
+	if err := snapshot.Save(filename, g.TakeSnapshot()); err != nil {
+		println("failed to save snapshot:", err.Error())
+		return
+	}
+	println("snapshot saved to", filename)
+}
+
+func (g *Game) LoadSnapshot(filename string) {
+	snap, err := snapshot.Load(filename)
+	if err == nil {
+		err = g.RestoreSnapshot(snap)
+	}
+	if err != nil {
+		println("failed to load snapshot:", err.Error())
+		return
+	}
+	println("snapshot loaded from", filename)
+}
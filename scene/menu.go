@@ -0,0 +1,111 @@
+package scene
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/nvlled/dinojump/ebitenx"
+	"github.com/nvlled/dinojump/input"
+)
+
+// glyph sizes of ebitenutil.DebugPrint
+const glyphWidth = 6
+const lineHeight = 16
+
+type MenuItem struct {
+	// Label returns the text of the item, so that
+	// it can change while the menu is shown.
+	Label func() string
+	// Select is called when the item is chosen with enter,
+	// or with the start button of a gamepad.
+	Select func()
+	// Change, if not nil, is called with -1 or 1
+	// when left or right is pressed on the item.
+	Change func(dir int)
+}
+
+func Item(label string, selectFn func()) MenuItem {
+	return MenuItem{
+		Label:  func() string { return label },
+		Select: selectFn,
+	}
+}
+
+// Menu is a list of items that is navigated with the
+// bound directions, see input.CurrentBindings.
+type Menu struct {
+	Title    string
+	Items    []MenuItem
+	Selected int
+}
+
+func (menu *Menu) Update() {
+	n := len(menu.Items)
+	if n == 0 {
+		return
+	}
+	if input.Ebiten.IsJustPressed(input.Up) {
+		menu.Selected = (menu.Selected + n - 1) % n
+	}
+	if input.Ebiten.IsJustPressed(input.Down) {
+		menu.Selected = (menu.Selected + 1) % n
+	}
+
+	item := menu.Items[menu.Selected]
+	if item.Change != nil {
+		if input.Ebiten.IsJustPressed(input.Left) {
+			item.Change(-1)
+		}
+		if input.Ebiten.IsJustPressed(input.Right) {
+			item.Change(1)
+		}
+	}
+	if item.Select != nil && IsConfirmJustPressed() {
+		item.Select()
+	}
+}
+
+var gamepadIDs []ebiten.GamepadID
+
+// IsConfirmJustPressed reports whether enter or
+// the start button of a gamepad is just pressed.
+func IsConfirmJustPressed() bool {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+		return true
+	}
+	gamepadIDs = ebiten.AppendGamepadIDs(gamepadIDs[:0])
+	for _, id := range gamepadIDs {
+		if inpututil.IsStandardGamepadButtonJustPressed(id, ebiten.StandardGamepadButtonCenterRight) {
+			return true
+		}
+	}
+	return false
+}
+
+// Draw draws the menu centered on the screen, over a translucent background.
+func (menu *Menu) Draw(screen *ebiten.Image) {
+	w, h := screen.Size()
+	ebitenx.DrawRect(screen, 0, 0, float64(w), float64(h), color.RGBA{0, 0, 0, 0xc0})
+
+	lines := len(menu.Items) + 2
+	y := h/2 - lines*lineHeight/2
+	DrawCentered(screen, menu.Title, y)
+	y += 2 * lineHeight
+
+	for i, item := range menu.Items {
+		label := item.Label()
+		if i == menu.Selected {
+			label = "> " + label + " <"
+		}
+		DrawCentered(screen, label, y)
+		y += lineHeight
+	}
+}
+
+// DrawCentered draws the debug text horizontally centered at y.
+func DrawCentered(screen *ebiten.Image, text string, y int) {
+	w, _ := screen.Size()
+	ebitenutil.DebugPrintAt(screen, text, w/2-len(text)*glyphWidth/2, y)
+}
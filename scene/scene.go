@@ -0,0 +1,92 @@
+package scene
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Scene is a screen of the game, like the title menu or the gameplay.
+// It's the same as an ebiten.Game, so that a single scene can
+// also be run directly with ebiten.RunGame.
+type Scene interface {
+	Update() error
+	Draw(screen *ebiten.Image)
+	Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int)
+}
+
+// Overlay is a scene that is drawn over the scene below it,
+// like a pause menu. Scenes below an overlay are drawn
+// but not updated, so they keep their state until
+// the overlay is popped.
+type Overlay interface {
+	Scene
+	IsOverlay() bool
+}
+
+// Stack is an ebiten.Game that only updates the scene on top.
+type Stack struct {
+	scenes []Scene
+}
+
+func New(scene Scene) *Stack {
+	return &Stack{scenes: []Scene{scene}}
+}
+
+func (stack *Stack) Top() Scene {
+	if len(stack.scenes) == 0 {
+		return nil
+	}
+	return stack.scenes[len(stack.scenes)-1]
+}
+
+func (stack *Stack) Push(scene Scene) {
+	stack.scenes = append(stack.scenes, scene)
+}
+
+// Pop removes the scene on top, unless it's the only one left.
+func (stack *Stack) Pop() {
+	if len(stack.scenes) <= 1 {
+		return
+	}
+	stack.scenes[len(stack.scenes)-1] = nil
+	stack.scenes = stack.scenes[:len(stack.scenes)-1]
+}
+
+// Replace replaces the scene on top.
+func (stack *Stack) Replace(scene Scene) {
+	stack.scenes[len(stack.scenes)-1] = scene
+}
+
+// Reset removes all the scenes, leaving only the given scene.
+func (stack *Stack) Reset(scene Scene) {
+	for i := range stack.scenes {
+		stack.scenes[i] = nil
+	}
+	stack.scenes = append(stack.scenes[:0], scene)
+}
+
+func (stack *Stack) Update() error {
+	return stack.Top().Update()
+}
+
+// Draw draws the scene on top, starting from
+// the first scene below it that is not an overlay.
+func (stack *Stack) Draw(screen *ebiten.Image) {
+	start := len(stack.scenes) - 1
+	for start > 0 && isOverlay(stack.scenes[start]) {
+		start--
+	}
+	for _, scene := range stack.scenes[start:] {
+		scene.Draw(screen)
+	}
+}
+
+// Layout uses the layout of the scene on top.
+// Overlays should have the same layout as the scene below.
+func (stack *Stack) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
+	return stack.Top().Layout(outsideWidth, outsideHeight)
+}
+
+func isOverlay(scene Scene) bool {
+	overlay, ok := scene.(Overlay)
+	return ok && overlay.IsOverlay()
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"os"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/nvlled/dinojump/dinos"
+	"github.com/nvlled/dinojump/scene"
+)
+
+// resultsDelay is how many ticks the results are shown
+// before they can be skipped.
+const resultsDelay = 30
+
+type titleScene struct {
+	game *Game
+	menu scene.Menu
+}
+
+func newTitleScene(g *Game) *titleScene {
+	return &titleScene{
+		game: g,
+		menu: scene.Menu{
+			Title: "DINOJUMP",
+			Items: []scene.MenuItem{
+				scene.Item("play", func() {
+					initialized.Do(g.Initialize)
+					g.scenes.Replace(g)
+				}),
+				{
+					Label: func() string { return fmt.Sprintf("dino: < %v >", dinos.All[g.dinoIndex].Name) },
+					Change: func(dir int) {
+						n := len(dinos.All)
+						g.ResetDino((g.dinoIndex + dir + n) % n)
+					},
+				},
+				scene.Item("quit", func() { os.Exit(0) }),
+			},
+		},
+	}
+}
+
+func (title *titleScene) Update() error {
+	title.menu.Update()
+	return nil
+}
+
+func (title *titleScene) Draw(screen *ebiten.Image) {
+	screen.Fill(color.RGBA{32, 82, 82, 0xff})
+	title.menu.Draw(screen)
+}
+
+func (title *titleScene) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return title.game.Layout(outsideWidth, outsideHeight)
+}
+
+type pauseScene struct {
+	game *Game
+	menu scene.Menu
+}
+
+func newPauseScene(g *Game) *pauseScene {
+	return &pauseScene{
+		game: g,
+		menu: scene.Menu{
+			Title: "PAUSED",
+			Items: []scene.MenuItem{
+				scene.Item("resume", g.scenes.Pop),
+				scene.Item("restart level", func() {
+					g.scenes.Pop()
+					g.RestartLevel()
+				}),
+				scene.Item("title", func() {
+					g.Restart()
+					g.scenes.Reset(newTitleScene(g))
+				}),
+				scene.Item("quit", func() { os.Exit(0) }),
+			},
+		},
+	}
+}
+
+func (pause *pauseScene) Update() error {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		pause.game.scenes.Pop()
+		return nil
+	}
+	pause.menu.Update()
+	return nil
+}
+
+func (pause *pauseScene) Draw(screen *ebiten.Image) {
+	pause.menu.Draw(screen)
+}
+
+func (pause *pauseScene) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return pause.game.Layout(outsideWidth, outsideHeight)
+}
+
+func (pause *pauseScene) IsOverlay() bool { return true }
+
+// resultsScene is shown when a level is completed,
+// and loads the next level when it's closed.
+type resultsScene struct {
+	game  *Game
+	ticks int
+}
+
+func (results *resultsScene) Update() error {
+	results.ticks++
+	if results.ticks > resultsDelay && scene.IsConfirmJustPressed() {
+		g := results.game
+		g.scenes.Pop()
+		g.NextLevel()
+	}
+	return nil
+}
+
+func (results *resultsScene) Draw(screen *ebiten.Image) {
+	g := results.game
+	screen.Fill(color.Black)
+
+	title := "LEVEL COMPLETE"
+	if g.levelIndex == len(g.levels)-1 {
+		title = "ALL LEVELS COMPLETE"
+	}
+	next := g.levels[(g.levelIndex+1)%len(g.levels)]
+
+	_, h := screen.Size()
+	y := h/2 - 40
+	scene.DrawCentered(screen, title, y)
+	scene.DrawCentered(screen, fmt.Sprintf("deaths: %v", g.dino.GetLife().Deaths), y+32)
+	scene.DrawCentered(screen, "next: "+next, y+48)
+	if results.ticks > resultsDelay {
+		scene.DrawCentered(screen, "press enter to continue", y+80)
+	}
+}
+
+func (results *resultsScene) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return results.game.Layout(outsideWidth, outsideHeight)
+}
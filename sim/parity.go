@@ -0,0 +1,107 @@
+package sim
+
+import (
+	"math"
+
+	"github.com/nvlled/dinojump/dinos"
+	"github.com/nvlled/dinojump/input"
+	"github.com/nvlled/dinojump/level"
+	"github.com/nvlled/dinojump/vector"
+)
+
+// Sample is the observable dino state after a tick.
+type Sample struct {
+	Tick   int
+	State  string
+	Pos    vector.T
+	Vel    vector.T
+	TileID int
+}
+
+func TakeSample(world *World) Sample {
+	sprite := world.Dino.GetSprite()
+	return Sample{
+		Tick:   world.Tick,
+		State:  world.Dino.StateName(),
+		Pos:    sprite.Pos,
+		Vel:    sprite.Vel,
+		TileID: sprite.CurrentTileID,
+	}
+}
+
+// Trace runs the same input states with each of the dino
+// implementations, and returns the samples indexed by [impl][tick].
+func Trace(level *level.T, spawn vector.T, states []input.State, impls []dinos.Impl) [][]Sample {
+	var trace [][]Sample
+	for _, impl := range impls {
+		script := input.NewScript(states...)
+		world := NewWorld(level, impl.New(level), script)
+		world.Spawn(spawn)
+
+		var samples []Sample
+		world.RunScript(func(w *World) {
+			samples = append(samples, TakeSample(w))
+		})
+		trace = append(trace, samples)
+	}
+	return trace
+}
+
+// Divergence is the first tick where a field of the samples
+// are not the same across the implementations.
+type Divergence struct {
+	Field   string
+	Tick    int
+	Samples []Sample
+}
+
+var ParityFields = []string{"pos", "vel", "state", "tile"}
+
+// FindDivergences compares the samples of a trace tick by tick,
+// and returns the first divergence of each field.
+// Float values that are within eps are considered the same.
+func FindDivergences(trace [][]Sample, eps float64) []Divergence {
+	var result []Divergence
+	if len(trace) < 2 {
+		return result
+	}
+
+	for _, field := range ParityFields {
+	TICKS:
+		for tick := range trace[0] {
+			a := trace[0][tick]
+			for _, samples := range trace[1:] {
+				if tick >= len(samples) || !sampleFieldEqual(field, &a, &samples[tick], eps) {
+					div := Divergence{Field: field, Tick: a.Tick}
+					for _, samples := range trace {
+						if tick < len(samples) {
+							div.Samples = append(div.Samples, samples[tick])
+						}
+					}
+					result = append(result, div)
+					break TICKS
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+func sampleFieldEqual(field string, a, b *Sample, eps float64) bool {
+	switch field {
+	case "pos":
+		return vectorEqual(a.Pos, b.Pos, eps)
+	case "vel":
+		return vectorEqual(a.Vel, b.Vel, eps)
+	case "state":
+		return a.State == b.State
+	case "tile":
+		return a.TileID == b.TileID
+	}
+	return true
+}
+
+func vectorEqual(a, b vector.T, eps float64) bool {
+	return math.Abs(a.X-b.X) <= eps && math.Abs(a.Y-b.Y) <= eps
+}
@@ -0,0 +1,84 @@
+package sim
+
+import (
+	"fmt"
+
+	"github.com/nvlled/dinojump/assets"
+	"github.com/nvlled/dinojump/dinos"
+	"github.com/nvlled/dinojump/input"
+	"github.com/nvlled/dinojump/level"
+	"github.com/nvlled/dinojump/vector"
+)
+
+// NewArena loads a small level with a floor, walls and a few platforms.
+func NewArena() (*level.T, error) {
+	return level.LoadFile(assets.FS, "levels/arena.level")
+}
+
+// World steps a dino and a level without opening a window.
+// The dino reads its input from the Input script instead of the keyboard.
+type World struct {
+	Level *level.T
+	Dino  dinos.Dino
+	Input *input.Script
+
+	Tick int
+}
+
+func NewWorld(level *level.T, dino dinos.Dino, script *input.Script) *World {
+	dinos.Configure(dino, level.RenderTileSize)
+	dino.SetLevel(level)
+	dino.SetInput(script)
+	level.Reset()
+
+	return &World{
+		Level: level,
+		Dino:  dino,
+		Input: script,
+	}
+}
+
+// Spawn places the dino at pos with zero velocity.
+func (world *World) Spawn(pos vector.T) {
+	sprite := world.Dino.GetSprite()
+	sprite.Pos = pos
+	sprite.Vel = vector.Zero
+}
+
+func (world *World) Step() {
+	world.Input.Next()
+	world.Level.Update()
+	world.Dino.Update()
+	world.Tick++
+}
+
+// Run steps the world for the given number of ticks.
+// If observe is not nil, it is called after each tick.
+func (world *World) Run(ticks int, observe func(*World)) {
+	for i := 0; i < ticks; i++ {
+		world.Step()
+		if observe != nil {
+			observe(world)
+		}
+	}
+}
+
+// RunScript steps the world until the end of the input script.
+func (world *World) RunScript(observe func(*World)) {
+	world.Run(world.Input.Len()-world.Input.Tick, observe)
+}
+
+// NewReplayWorld creates a world that plays back the replay
+// with the dino implementation it was recorded with.
+func NewReplayWorld(level *level.T, replay *input.Replay) (*World, error) {
+	index := dinos.IndexOf(replay.Dino)
+	if index < 0 {
+		return nil, fmt.Errorf("unknown dino implementation %q", replay.Dino)
+	}
+
+	script := input.NewScript(replay.States...)
+	world := NewWorld(level, dinos.All[index].New(level), script)
+	world.Spawn(replay.Spawn)
+
+	return world, nil
+}
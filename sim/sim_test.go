@@ -0,0 +1,276 @@
+//go:build !nodisplay
+
+// The dinos import ebiten, which initializes GLFW when the package
+// is loaded, so these tests need a display even though no window is
+// opened. Ebiten panics before any test can check for one, so on a
+// machine without one, either run them with a virtual display:
+//
+//	xvfb-run go test ./sim
+//
+// or leave them out with the nodisplay tag, like the other tests
+// that load ebiten:
+//
+//	go test -tags nodisplay ./...
+package sim
+
+import (
+	"testing"
+
+	"github.com/nvlled/dinojump/dinos"
+	"github.com/nvlled/dinojump/input"
+	"github.com/nvlled/dinojump/tuning"
+	"github.com/nvlled/dinojump/vector"
+)
+
+// traceArena runs the script with each of the dino implementations
+// from the spawn of the arena.
+func traceArena(t *testing.T, script *input.Script) [][]Sample {
+	t.Helper()
+	level, err := NewArena()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spawn, _ := level.SpawnPoint()
+	return Trace(level, spawn, script.States, dinos.All)
+}
+
+func visited(samples []Sample, state string) bool {
+	for _, s := range samples {
+		if s.State == state {
+			return true
+		}
+	}
+	return false
+}
+
+func TestJump(t *testing.T) {
+	script := input.NewScript().Wait(30).Hold(20, input.Jump).Wait(90)
+	for i, samples := range traceArena(t, script) {
+		name := dinos.All[i].Name
+		ground := samples[29]
+		if ground.State != "idle" {
+			t.Fatalf("%v: expected idle before the jump, got %v", name, ground.State)
+		}
+		if !visited(samples[30:], "jump") {
+			t.Errorf("%v: never jumped", name)
+		}
+
+		top := ground.Pos.Y
+		for _, s := range samples {
+			if s.Pos.Y < top {
+				top = s.Pos.Y
+			}
+		}
+		if ground.Pos.Y-top < 50 {
+			t.Errorf("%v: jumped only %v pixels high", name, ground.Pos.Y-top)
+		}
+
+		last := samples[len(samples)-1]
+		if last.State != "idle" || last.Pos != ground.Pos {
+			t.Errorf("%v: expected idle at %v after landing, got %v at %v", name, ground.Pos, last.State, last.Pos)
+		}
+	}
+}
+
+func TestFly(t *testing.T) {
+	script := input.NewScript().
+		Wait(30).Hold(130, input.Right).
+		Hold(10, input.Right, input.Jump).Hold(5, input.Right).
+		Hold(10, input.Right, input.Jump).Hold(5, input.Right).
+		Hold(10, input.Right, input.Jump).Hold(10, input.Right)
+	up := script.Len()
+	script.Hold(20, input.Up)
+	stop := script.Len()
+	script.Hold(10, input.Down, input.Jump).Wait(60)
+
+	for i, samples := range traceArena(t, script) {
+		name := dinos.All[i].Name
+		ground := samples[29].Pos.Y
+		if !visited(samples, "fly") {
+			t.Fatalf("%v: never flew", name)
+		}
+		if s := samples[up]; s.State != "fly" {
+			t.Fatalf("%v: expected to fly on tick %v, got %v", name, s.Tick, s.State)
+		}
+		if samples[stop-1].Pos.Y >= samples[up].Pos.Y {
+			t.Errorf("%v: didn't fly up while holding up", name)
+		}
+
+		last := samples[len(samples)-1]
+		if last.State == "fly" || last.Pos.Y != ground {
+			t.Errorf("%v: expected to land after flying, got %v at %v", name, last.State, last.Pos)
+		}
+	}
+}
+
+func TestBounce(t *testing.T) {
+	script := input.NewScript().Wait(30).Hold(250, input.Right).Wait(90)
+	for i, samples := range traceArena(t, script) {
+		name := dinos.All[i].Name
+		if !visited(samples, "bounce") {
+			t.Fatalf("%v: never bounced off the wall", name)
+		}
+
+		right := samples[0].Pos.X
+		for _, s := range samples {
+			if s.Pos.X > right {
+				right = s.Pos.X
+			}
+		}
+		last := samples[len(samples)-1]
+		if last.Pos.X >= right {
+			t.Errorf("%v: expected to bounce back from %v, ended at %v", name, right, last.Pos.X)
+		}
+		if last.State != "idle" || last.Pos.Y != samples[29].Pos.Y {
+			t.Errorf("%v: expected idle on the ground, got %v at %v", name, last.State, last.Pos)
+		}
+	}
+}
+
+// The arena has a hill left of the spawn, from column 3 to 12, that
+// rises with 22.5° and 45° slopes to a flat top two tiles higher than
+// the ground, and goes down the other side the same way.
+func TestRunOverHill(t *testing.T) {
+	script := input.NewScript().Wait(30).Hold(110, input.Left)
+	for i, samples := range traceArena(t, script) {
+		name := dinos.All[i].Name
+		ground := samples[29].Pos.Y
+		top := ground
+		crossed := false
+		for _, s := range samples {
+			if s.Pos.X > 150 && s.Pos.X < 650 && s.State != "run" {
+				t.Fatalf("%v: expected to run over the hill, got %v on tick %v at %v", name, s.State, s.Tick, s.Pos)
+			}
+			if s.Pos.Y < top {
+				top = s.Pos.Y
+			}
+			if s.Pos.X < 150 && s.Pos.Y == ground && s.State == "run" {
+				crossed = true
+			}
+		}
+		if ground-top != 100 {
+			t.Errorf("%v: expected to run 100 pixels up the hill, got %v", name, ground-top)
+		}
+		if !crossed {
+			t.Errorf("%v: didn't run down to the ground on the other side of the hill", name)
+		}
+	}
+}
+
+// traceImpl runs the script with the i-th dino implementation from spawn.
+func traceImpl(t *testing.T, i int, spawn vector.T, script *input.Script) []Sample {
+	t.Helper()
+	level, err := NewArena()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Trace(level, spawn, script.States, dinos.All[i:i+1])[0]
+}
+
+// findState returns the index of the first sample from the
+// index from whose state is one of the states, or -1.
+func findState(samples []Sample, from int, states ...string) int {
+	for i := from; i < len(samples); i++ {
+		for _, state := range states {
+			if samples[i].State == state {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// countJumps returns how many times the samples go into the jump state.
+func countJumps(samples []Sample) int {
+	n := 0
+	for i, s := range samples {
+		if s.State == "jump" && (i == 0 || samples[i-1].State != "jump") {
+			n++
+		}
+	}
+	return n
+}
+
+// The one-way platform at column 18 to 20 of the arena, two tiles
+// above the ground, is the ledge that the dino walks off. Its left
+// side has nothing above it to land on while jumping.
+var ledgeSpawn = vector.Create(975, 200)
+
+func TestCoyoteTime(t *testing.T) {
+	cases := []struct {
+		name  string
+		after int
+		jumps int
+	}{
+		{"in the coyote time", 2, tuning.Default.MaxJumps},
+		// Walking off the ledge used up the jump from the ground.
+		{"after the coyote time", tuning.Default.CoyoteTicks + 4, tuning.Default.MaxJumps - 1},
+	}
+	for i, impl := range dinos.All {
+		walk := traceImpl(t, i, ledgeSpawn, input.NewScript().Wait(30).Hold(60, input.Left))
+		walkOff := findState(walk, 30, "fall")
+		if walkOff < 0 {
+			t.Fatalf("%v: never walked off the ledge", impl.Name)
+		}
+
+		for _, c := range cases {
+			script := input.NewScript().Wait(30).Hold(walkOff-30+c.after, input.Left)
+			for j := 0; j < tuning.Default.MaxJumps; j++ {
+				script.Hold(10, input.Jump).Wait(5)
+			}
+			samples := traceImpl(t, i, ledgeSpawn, script)
+			if s := samples[walkOff+c.after-1]; s.State != "fall" {
+				t.Fatalf("%v %v: expected to fall before jumping, got %v", impl.Name, c.name, s.State)
+			}
+			if n := countJumps(samples[walkOff:]); n != c.jumps {
+				t.Errorf("%v %v: expected %v jumps after walking off, got %v", impl.Name, c.name, c.jumps, n)
+			}
+		}
+	}
+}
+
+func TestJumpBuffer(t *testing.T) {
+	spawn := vector.Create(825, 300)
+	tripleJump := func() *input.Script {
+		script := input.NewScript().Wait(30)
+		for j := 0; j < tuning.Default.MaxJumps; j++ {
+			script.Hold(10, input.Jump).Wait(5)
+		}
+		return script
+	}
+
+	cases := []struct {
+		name   string
+		before int
+		jump   bool
+	}{
+		{"in the jump buffer", 3, true},
+		{"before the jump buffer", tuning.Default.JumpBufferTicks + 3, false},
+	}
+	for i, impl := range dinos.All {
+		// With no jumps left, pressing jump in the air
+		// doesn't jump until the dino lands.
+		fall := traceImpl(t, i, spawn, tripleJump().Wait(80))
+		land := findState(fall, tripleJump().Len(), "idle", "walk")
+		if land < 0 {
+			t.Fatalf("%v: never landed after the triple jump", impl.Name)
+		}
+
+		for _, c := range cases {
+			script := tripleJump()
+			script.Wait(land - c.before - script.Len()).Press(input.Jump).Wait(40)
+			samples := traceImpl(t, i, spawn, script)
+			if s := samples[land-c.before]; s.State != "fall" {
+				t.Fatalf("%v %v: expected to press jump while falling, got %v", impl.Name, c.name, s.State)
+			}
+
+			jumped := samples[land].State == "jump"
+			if jumped != c.jump {
+				t.Errorf("%v %v: expected jumping on landing to be %v, got %v", impl.Name, c.name, c.jump, samples[land].State)
+			}
+			if !c.jump && findState(samples, land, "jump") >= 0 {
+				t.Errorf("%v %v: jumped after landing", impl.Name, c.name)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/nvlled/dinojump/level"
+	"github.com/nvlled/dinojump/rect"
+	"github.com/nvlled/dinojump/vector"
+)
+
+const Version = 2
+
+// T is the state of a running game, saved as JSON.
+type T struct {
+	Version int
+
+	// Impl is the name of the dino implementation.
+	Impl       string
+	Level      string
+	LevelIndex int
+
+	Camera      rect.T
+	CameraInner rect.T
+
+	Dino      Dino
+	Platforms []level.PlatformState
+}
+
+// Dino is the state of a dino, common to all the implementations.
+//
+// State is the name of the controller state. Implementations that can't
+// resume a state in the middle, like the coroutine dino in a jump,
+// restore it as the closest state they can resume instead, see Resumable.
+type Dino struct {
+	State    string
+	Pos      vector.T
+	Vel      vector.T
+	Flip     byte
+	Rotation float64
+	TileID   int
+
+	// Gravity and Collision are whether the dino
+	// applies gravity and collides with the level.
+	Gravity   bool
+	Collision bool
+
+	Hit         byte
+	Ground      uint16
+	DropThrough bool
+	// Platform is the index of the platform the dino stands on, or -1.
+	Platform int
+	LastRect rect.T
+
+	Jumps      int
+	Turns      int
+	JumpCharge int
+	PreJump    bool
+	DeadTicks  int
+	Coyote     int
+	JumpBuffer int
+	WallDir    float64
+	Ledge      level.Ledge
+
+	Checkpoint vector.T
+	Deaths     int
+}
+
+// Resumable returns the state that a saved state is restored as,
+// given the states that an implementation can resume. Jumps,
+// bounces, wall slides, ledge grabs and climbs continue as falls, since
+// the velocity is kept anyway.
+func Resumable(state string, resumable ...string) string {
+	for _, s := range resumable {
+		if s == state {
+			return state
+		}
+	}
+	switch state {
+	case "jump", "jump charge", "bounce", "wall slide", "wall jump",
+		"ledge hang", "ledge climb", "climb":
+		return "fall"
+	}
+	return "idle"
+}
+
+func Save(filename string, snap *T) error {
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+func Load(filename string) (*T, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	snap := &T{}
+	if err := json.Unmarshal(data, snap); err != nil {
+		return nil, fmt.Errorf("snapshot: %v: %w", filename, err)
+	}
+	if snap.Version != Version {
+		return nil, fmt.Errorf("snapshot: %v: unsupported version %v", filename, snap.Version)
+	}
+	return snap, nil
+}
@@ -0,0 +1,106 @@
+//go:build !nodisplay
+
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nvlled/dinojump/level"
+	"github.com/nvlled/dinojump/rect"
+	"github.com/nvlled/dinojump/vector"
+)
+
+func TestSaveLoad(t *testing.T) {
+	snap := &T{
+		Version:     Version,
+		Impl:        "enums",
+		Level:       "levels/level1.level",
+		LevelIndex:  1,
+		Camera:      rect.Create(100, 50, 500, 400),
+		CameraInner: rect.Create(250, 175, 200, 150),
+		Dino: Dino{
+			State:      "wall slide",
+			Pos:        vector.Create(312.5, 187.25),
+			Vel:        vector.Create(0, 1.5),
+			Flip:       0b10,
+			Rotation:   0.1,
+			TileID:     12,
+			Gravity:    true,
+			Collision:  true,
+			Hit:        0b0100,
+			Ground:     level.FlagSolid | level.FlagSlippery,
+			Platform:   -1,
+			LastRect:   rect.Create(300, 175, 25, 25),
+			Jumps:      2,
+			Turns:      7,
+			Coyote:     3,
+			JumpBuffer: 1,
+			WallDir:    1,
+			Ledge:      level.Ledge{Corner: vector.Create(350, 150), Dir: 1},
+			Checkpoint: vector.Create(175, 100),
+			Deaths:     4,
+		},
+		Platforms: []level.PlatformState{
+			{Pos: vector.Create(2000, 350), Delta: vector.Create(2, 0), Next: 1, Dir: 1},
+			{Pos: vector.Create(2800, 200), Delta: vector.Create(0, -1.5), Next: 0, Dir: -1},
+		},
+	}
+
+	filename := filepath.Join(t.TempDir(), "quick.snapshot")
+	if err := Save(filename, snap); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, snap) {
+		t.Errorf("expected %+v, got %+v", snap, loaded)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{"old version", `{"Version": 1, "Impl": "enums"}`, "unsupported version 1"},
+		{"no version", `{"Impl": "enums"}`, "unsupported version 0"},
+		{"not json", `Version: 2`, "invalid character"},
+	}
+	for _, c := range cases {
+		filename := filepath.Join(dir, c.name+".snapshot")
+		if err := os.WriteFile(filename, []byte(c.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := Load(filename)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%v: expected an error with %q, got %v", c.name, c.err, err)
+		}
+	}
+}
+
+func TestResumable(t *testing.T) {
+	cases := []struct {
+		state     string
+		resumable []string
+		expected  string
+	}{
+		{"wall slide", []string{"idle", "wall slide"}, "wall slide"},
+		{"wall slide", []string{"idle", "fall"}, "fall"},
+		{"jump charge", nil, "fall"},
+		{"run", []string{"idle", "fall"}, "idle"},
+		{"dead", nil, "idle"},
+	}
+	for _, c := range cases {
+		if state := Resumable(c.state, c.resumable...); state != c.expected {
+			t.Errorf("%q with %v: expected %q, got %q", c.state, c.resumable, c.expected, state)
+		}
+	}
+}
@@ -18,6 +18,11 @@ var Debug = false
 
 var emptyTile = image.Rectangle{}
 
+// maxLag is how far the sprite can move in an update and still
+// be interpolated, so that it isn't drawn sliding across the level
+// after it was moved somewhere else.
+const maxLag = 100
+
 type f64 = float64
 
 type T struct {
@@ -31,6 +36,12 @@ type T struct {
 	Pos vector.T
 	Vel vector.T
 
+	// PrevPos is where the sprite was at the start of the last update.
+	// DrawLag is how far back between Pos and PrevPos the sprite
+	// is drawn, for drawing in between fixed updates.
+	PrevPos vector.T
+	DrawLag float64
+
 	//Animation       string
 	//AnimationFrames map[string][]image.Rectangle
 
@@ -102,6 +113,10 @@ func New(atlas *ebiten.Image, numCols, numRows int /*, animationIndices map[stri
 	}
 }
 
+func (sprite *T) GetSprite() *T {
+	return sprite
+}
+
 func (sprite *T) GetTileCount() (cols, rows int) {
 	return sprite.cols, sprite.rows
 }
@@ -151,6 +166,7 @@ func (sprite *Sprite) TickFrame() {
 */
 
 func (sprite *T) Update() {
+	sprite.PrevPos = sprite.Pos
 	sprite.Rect.Min = vector.AddXY(&sprite.Pos, -sprite.DrawSize.X/2, -sprite.DrawSize.Y/2)
 	sprite.Rect.Max = vector.AddXY(&sprite.Pos, sprite.DrawSize.X/2, sprite.DrawSize.Y/2)
 
@@ -162,6 +178,11 @@ func (sprite *T) Draw(canvas *ebiten.Image) {
 
 	vr := sprite.GetViewRect()
 	cr := sprite.GetCollisionRect()
+	if lag := vector.Sub(&sprite.PrevPos, &sprite.Pos); lag.Length() < maxLag {
+		lag.Scale(sprite.DrawLag)
+		vr.Min.Add(&lag)
+		vr.Max.Add(&lag)
+	}
 	mid := vr.Mid()
 
 	//sprite.imageOp.Reset()
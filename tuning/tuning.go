@@ -0,0 +1,242 @@
+package tuning
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/nvlled/dinojump/assets"
+)
+
+// A tuning file sets the fields of T by their tuning tag,
+// one per line. Fields that are not in the file keep their
+// Default value.
+//
+//	# comments start with #
+//	gravity: 0.25
+//	max-jumps: 3
+
+// T are the physics parameters of the dino, shared by all the dino
+// implementations. Speeds are in pixels per tick, and dampings
+// are multiplied to the velocity on every tick.
+type T struct {
+	Gravity  float64 `tuning:"gravity"`
+	MaxSpeed float64 `tuning:"max-speed"`
+	MaxJumps int     `tuning:"max-jumps"`
+
+	WalkStartSpeed float64 `tuning:"walk-start-speed"`
+	WalkAccel      float64 `tuning:"walk-accel"`
+	TurnDamping    float64 `tuning:"turn-damping"`
+	// RunSpeed is how fast the dino walks before it runs, and
+	// how fast it can move in the air before it can't steer.
+	RunSpeed float64 `tuning:"run-speed"`
+	RunAccel float64 `tuning:"run-accel"`
+
+	BrakeStopSpeed         float64 `tuning:"brake-stop-speed"`
+	BrakeDamping           float64 `tuning:"brake-damping"`
+	BrakeCounterDamping    float64 `tuning:"brake-counter-damping"`
+	SlipperyDamping        float64 `tuning:"slippery-damping"`
+	SlipperyCounterDamping float64 `tuning:"slippery-counter-damping"`
+
+	// BounceSpeed is how fast the dino has to hit a wall to bounce off it.
+	BounceSpeed     float64 `tuning:"bounce-speed"`
+	BounceRebound   float64 `tuning:"bounce-rebound"`
+	BounceJumpSpeed float64 `tuning:"bounce-jump-speed"`
+	BounceDamping   float64 `tuning:"bounce-damping"`
+	BounceStopSpeed float64 `tuning:"bounce-stop-speed"`
+	BouncyTileSpeed float64 `tuning:"bouncy-tile-speed"`
+
+	JumpSpeed          float64 `tuning:"jump-speed"`
+	JumpHoldDamping    float64 `tuning:"jump-hold-damping"`
+	JumpReleaseDamping float64 `tuning:"jump-release-damping"`
+	// JumpFallSpeed is how slow the dino rises before it falls.
+	JumpFallSpeed  float64 `tuning:"jump-fall-speed"`
+	CeilingRebound float64 `tuning:"ceiling-rebound"`
+	AirNudge       float64 `tuning:"air-nudge"`
+	SwerveDamping  float64 `tuning:"swerve-damping"`
+	SpinRate       float64 `tuning:"spin-rate"`
+
+	// CoyoteTicks is how long the dino can still jump from the ground
+	// after walking off a ledge, and JumpBufferTicks is how long a jump
+	// that is pressed in the air is kept until the dino lands. Once the
+	// coyote ticks are over, walking off the ledge counts as the jump
+	// from the ground, leaving MaxJumps-1 jumps in the air.
+	CoyoteTicks     int `tuning:"coyote-ticks"`
+	JumpBufferTicks int `tuning:"jump-buffer-ticks"`
+
+	// WallSlideSpeed is how fast the dino slides down a wall it
+	// pushes against, and a wall jump launches it away from the
+	// wall at WallJumpPush and up at WallJumpSpeed.
+	WallSlideSpeed float64 `tuning:"wall-slide-speed"`
+	WallJumpPush   float64 `tuning:"wall-jump-push"`
+	WallJumpSpeed  float64 `tuning:"wall-jump-speed"`
+
+	// LedgeGrabReach is how far the top of the dino can fall past
+	// the top of a ledge and still grab it, besides its fall speed.
+	LedgeGrabReach  float64 `tuning:"ledge-grab-reach"`
+	LedgeClimbSpeed float64 `tuning:"ledge-climb-speed"`
+
+	// ClimbSpeed is how fast the dino moves on climbable tiles.
+	ClimbSpeed float64 `tuning:"climb-speed"`
+
+	FlyMaxSpeed     float64 `tuning:"fly-max-speed"`
+	FlyAccel        float64 `tuning:"fly-accel"`
+	FlyDamping      float64 `tuning:"fly-damping"`
+	FlyEntryDamping float64 `tuning:"fly-entry-damping"`
+
+	// JumpChargeTicks is how long the dino has to be jumping
+	// before holding up starts the jump charge.
+	JumpChargeTicks int     `tuning:"jump-charge-ticks"`
+	ChargeSpins     int     `tuning:"charge-spins"`
+	ChargeSpinIdle  int     `tuning:"charge-spin-idle"`
+	ChargeShakes    int     `tuning:"charge-shakes"`
+	ChargeShakeIdle int     `tuning:"charge-shake-idle"`
+	DashAimIdle     int     `tuning:"dash-aim-idle"`
+	DashSpeed       float64 `tuning:"dash-speed"`
+	DashDamping     float64 `tuning:"dash-damping"`
+	DashStopSpeed   float64 `tuning:"dash-stop-speed"`
+
+	DeadJumpSpeed float64 `tuning:"dead-jump-speed"`
+	// DeadTicks is how long the dino stays dead before respawning.
+	DeadTicks int `tuning:"dead-ticks"`
+}
+
+var Default = T{
+	Gravity:  0.25,
+	MaxSpeed: 20,
+	MaxJumps: 3,
+
+	WalkStartSpeed: 0.5,
+	WalkAccel:      0.1,
+	TurnDamping:    0.5,
+	RunSpeed:       4.5,
+	RunAccel:       0.2,
+
+	BrakeStopSpeed:         1,
+	BrakeDamping:           0.97,
+	BrakeCounterDamping:    0.90,
+	SlipperyDamping:        0.99,
+	SlipperyCounterDamping: 0.97,
+
+	BounceSpeed:     11,
+	BounceRebound:   0.8,
+	BounceJumpSpeed: 4.5,
+	BounceDamping:   0.9,
+	BounceStopSpeed: 1,
+	BouncyTileSpeed: 8,
+
+	JumpSpeed:          7.5,
+	JumpHoldDamping:    0.95,
+	JumpReleaseDamping: 0.55,
+	JumpFallSpeed:      1,
+	CeilingRebound:     0.3,
+	AirNudge:           1,
+	SwerveDamping:      0.7,
+	SpinRate:           0.1,
+
+	CoyoteTicks:     6,
+	JumpBufferTicks: 6,
+
+	WallSlideSpeed: 1.5,
+	WallJumpPush:   4,
+	WallJumpSpeed:  6,
+
+	LedgeGrabReach:  6,
+	LedgeClimbSpeed: 2,
+
+	ClimbSpeed: 2,
+
+	FlyMaxSpeed:     10,
+	FlyAccel:        1,
+	FlyDamping:      0.97,
+	FlyEntryDamping: 0.8,
+
+	JumpChargeTicks: 40,
+	ChargeSpins:     10,
+	ChargeSpinIdle:  150,
+	ChargeShakes:    30,
+	ChargeShakeIdle: 200,
+	DashAimIdle:     100,
+	DashSpeed:       70,
+	DashDamping:     0.99,
+	DashStopSpeed:   5,
+
+	DeadJumpSpeed: 6,
+	DeadTicks:     60,
+}
+
+// Current is the tuning that the dinos use. It's only
+// replaced between updates, see Load.
+var Current = Default
+
+type ParseError struct {
+	Filename string
+	Line     int
+	Msg      string
+}
+
+func (err *ParseError) Error() string {
+	return fmt.Sprintf("%v:%v: %v", err.Filename, err.Line, err.Msg)
+}
+
+// Load reads a tuning file from the disk, or the
+// embedded one if there is no such file on the disk.
+func Load(filename string) (T, error) {
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Parse(filename, assets.TuningData)
+	}
+	if err != nil {
+		return Default, err
+	}
+	return Parse(filename, data)
+}
+
+func Parse(filename string, data []byte) (T, error) {
+	result := Default
+	fields := map[string]reflect.Value{}
+	v := reflect.ValueOf(&result).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		fields[v.Type().Field(i).Tag.Get("tuning")] = v.Field(i)
+	}
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		errorf := func(format string, args ...any) error {
+			return &ParseError{Filename: filename, Line: i + 1, Msg: fmt.Sprintf(format, args...)}
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return Default, errorf("expected \"key: value\"")
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		field, ok := fields[key]
+		if !ok {
+			return Default, errorf("unknown parameter %q", key)
+		}
+
+		switch field.Kind() {
+		case reflect.Int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return Default, errorf("%v must be an integer, got %q", key, value)
+			}
+			field.SetInt(int64(n))
+		case reflect.Float64:
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return Default, errorf("%v must be a number, got %q", key, value)
+			}
+			field.SetFloat(n)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,88 @@
+package tuning
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nvlled/dinojump/assets"
+)
+
+func TestParse(t *testing.T) {
+	data := "# a comment\n\ngravity: 0.5\n  max-jumps : 2\ndash-speed:80\n"
+	result, err := Parse("test.txt", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Default
+	expected.Gravity = 0.5
+	expected.MaxJumps = 2
+	expected.DashSpeed = 80
+	if result != expected {
+		t.Errorf("expected %+v, got %+v", expected, result)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+		line int
+		msg  string
+	}{
+		{"unknown key", "gravity: 0.5\ngravty: 0.5\n", 2, `unknown parameter "gravty"`},
+		{"bad float", "# floats\n\ngravity: fast\n", 3, `gravity must be a number, got "fast"`},
+		{"bad int", "gravity: 0.5\nmax-jumps: 2.5\n", 2, `max-jumps must be an integer, got "2.5"`},
+		{"missing colon", "gravity 0.5\n", 1, `expected "key: value"`},
+	}
+	for _, c := range cases {
+		current := Current
+		result, err := Parse("test.txt", []byte(c.data))
+
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("%v: expected a parse error, got %v", c.name, err)
+			continue
+		}
+		if perr.Filename != "test.txt" || perr.Line != c.line || perr.Msg != c.msg {
+			t.Errorf("%v: expected %q on line %v, got %v", c.name, c.msg, c.line, perr)
+		}
+		if result != Default {
+			t.Errorf("%v: expected the default tuning on errors, got %+v", c.name, result)
+		}
+		if Current != current {
+			t.Errorf("%v: the current tuning changed", c.name)
+		}
+	}
+}
+
+// The tuning file in the assets lists every parameter with its default.
+func TestDefaultMatchesAssets(t *testing.T) {
+	result, err := Parse("tuning.txt", assets.TuningData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != Default {
+		t.Errorf("expected the assets to have the defaults %+v, got %+v", Default, result)
+	}
+
+	typ := reflect.TypeOf(Default)
+	for i := 0; i < typ.NumField(); i++ {
+		key := typ.Field(i).Tag.Get("tuning")
+		if !strings.Contains(string(assets.TuningData), "\n"+key+":") {
+			t.Errorf("%v is missing from the assets", key)
+		}
+	}
+}
+
+func TestLoadFallsBackToAssets(t *testing.T) {
+	result, err := Load("no-such-tuning.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != Default {
+		t.Errorf("expected the tuning from the assets, got %+v", result)
+	}
+}